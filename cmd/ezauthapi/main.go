@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/josuebrunel/ezauth"
 	"github.com/josuebrunel/ezauth/pkg/config"
@@ -25,6 +31,50 @@ func main() {
 		log.Fatalf("failed to run migrations: %v", err)
 	}
 
-	xlog.Info("starting server")
-	auth.Handler.Run()
+	// Reload the JWT keyring on SIGHUP so rotated keys and secrets are picked up without a restart.
+	// The environment is read again for the JWT settings only; other settings still need a restart.
+	go func() {
+		sighup := make(chan os.Signal, 1)
+		signal.Notify(sighup, syscall.SIGHUP)
+		for range sighup {
+			reloaded, err := config.LoadConfig()
+			if err != nil {
+				xlog.Error("failed to reload config", "error", err)
+				continue
+			}
+			if err := auth.Service.Keys.Reload(&reloaded); err != nil {
+				xlog.Error("failed to reload jwt keys", "error", err)
+			}
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Purge the accounts whose deletion grace period is over
+	go auth.Service.UserPurgeRun(ctx)
+
+	// Write the recorded user activity periodically
+	go auth.Service.ActivityFlushRun(ctx)
+
+	srv := &http.Server{Addr: cfg.Addr, Handler: auth.Handler}
+	go func() {
+		xlog.Info("starting server", "addr", cfg.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	xlog.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.TimeOut)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		xlog.Error("failed to shut down server", "error", err)
+	}
+	// Requests are drained, so no activity is recorded after this flush
+	if err := auth.Service.ActivityFlush(shutdownCtx); err != nil {
+		xlog.Error("failed to flush user activity", "error", err)
+	}
 }
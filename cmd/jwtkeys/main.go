@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/josuebrunel/ezauth/pkg/service"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+func main() {
+	alg := flag.String("alg", service.AlgRS256, "signing algorithm of the new key (RS256, ES256, EdDSA)")
+	key := flag.String("key", "", "path of the active private key (EZAUTH_JWT_PRIVATE_KEY_FILE)")
+	retiredDir := flag.String("retired-dir", "", "directory where the retired public keys are written")
+	rotate := flag.Bool("rotate", false, "retire the active key and generate a new one")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintln(out, "Usage:")
+		fmt.Fprintln(out, "  jwtkeys -key <file> [-retired-dir <dir>]")
+		fmt.Fprintln(out, "    \tprint the kid of the active key and the JWKS of the active and retired keys")
+		fmt.Fprintln(out, "  jwtkeys -rotate -key <file> -retired-dir <dir> [-alg <alg>]")
+		fmt.Fprintln(out, "    \tretire the active key and generate a new one")
+		fmt.Fprintln(out)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *key == "" || (*rotate && *retiredDir == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *rotate {
+		retired, active, err := service.RotateKeyFile(*alg, *key, *retiredDir)
+		if err != nil {
+			xlog.Error("failed to rotate key", "error", err)
+			os.Exit(1)
+		}
+		xlog.Info("key rotated", "retired", retired, "active", active)
+		fmt.Printf("Make sure EZAUTH_JWT_VERIFY_KEY_FILES includes %s/*.pem, then send SIGHUP to ezauthapi or restart it.\n", *retiredDir)
+		return
+	}
+
+	if err := printKeys(*key, *retiredDir); err != nil {
+		xlog.Error("failed to read keys", "error", err)
+		os.Exit(1)
+	}
+}
+
+// printKeys prints the kid of the active key and the JWKS of the active and retired keys.
+func printKeys(key, retiredDir string) error {
+	active, err := service.LoadKeyFile(key)
+	if err != nil {
+		return err
+	}
+	keys := []*service.SigningKey{active}
+
+	if retiredDir != "" {
+		paths, err := filepath.Glob(filepath.Join(retiredDir, "*.pem"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			retired, err := service.LoadKeyFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			keys = append(keys, retired)
+		}
+	}
+
+	set := service.JWKSet{Keys: []service.JWK{}}
+	for _, k := range keys {
+		if jwk, ok := k.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Active kid: %s\n%s\n", active.ID, data)
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/josuebrunel/ezauth/pkg/config"
 	"github.com/josuebrunel/ezauth/pkg/db/migrations"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
 	"github.com/josuebrunel/ezauth/pkg/db/repository"
 	"github.com/josuebrunel/ezauth/pkg/handler"
 	"github.com/josuebrunel/ezauth/pkg/service"
@@ -26,7 +27,8 @@ type EzAuth struct {
 // New creates a new EzAuth instance from a config.
 // It handles database connection based on the provided configuration.
 // path is the base URL path where the authentication routes will be mounted (e.g., "auth").
-func New(cfg *config.Config, path string) (*EzAuth, error) {
+// opts configure the handler, for instance with handler.WithTokenExtractors.
+func New(cfg *config.Config, path string, opts ...handler.HandlerOption) (*EzAuth, error) {
 	repo, err := repository.Open(repository.Opts{
 		Dialect: cfg.DB.Dialect,
 		DSN:     cfg.DB.DSN,
@@ -35,8 +37,11 @@ func New(cfg *config.Config, path string) (*EzAuth, error) {
 		return nil, err
 	}
 
-	svc := service.New(cfg, repo, path)
-	h := handler.New(svc, path)
+	svc, err := service.New(cfg, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	h := handler.New(svc, path, opts...)
 
 	return &EzAuth{
 		Config:  cfg,
@@ -48,10 +53,13 @@ func New(cfg *config.Config, path string) (*EzAuth, error) {
 
 // NewWithDB creates a new EzAuth instance using an existing database connection.
 // path is the base URL path where the authentication routes will be mounted (e.g., "auth").
-func NewWithDB(cfg *config.Config, db *sql.DB, path string) (*EzAuth, error) {
+func NewWithDB(cfg *config.Config, db *sql.DB, path string, opts ...handler.HandlerOption) (*EzAuth, error) {
 	repo := repository.New(db, cfg.DB.Dialect)
-	svc := service.New(cfg, repo, path)
-	h := handler.New(svc, path)
+	svc, err := service.New(cfg, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	h := handler.New(svc, path, opts...)
 
 	return &EzAuth{
 		Config:  cfg,
@@ -71,7 +79,41 @@ func (e *EzAuth) AuthMiddleware(next http.Handler) http.Handler {
 	return e.Handler.AuthMiddleware(next)
 }
 
+// OptionalAuthMiddleware returns the middleware authenticating requests with a bearer token
+// and letting anonymous requests through.
+func (e *EzAuth) OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return e.Handler.OptionalAuthMiddleware(next)
+}
+
+// RequireRole returns a middleware allowing only users having role.
+// It must be used after AuthMiddleware.
+func (e *EzAuth) RequireRole(role string) func(http.Handler) http.Handler {
+	return e.Handler.RequireRole(role)
+}
+
+// RequireAnyRole returns a middleware allowing only users having at least one of roles.
+// It must be used after AuthMiddleware.
+func (e *EzAuth) RequireAnyRole(roles ...string) func(http.Handler) http.Handler {
+	return e.Handler.RequireAnyRole(roles...)
+}
+
+// RequirePermission returns a middleware allowing only users whose roles grant permission.
+// It must be used after AuthMiddleware.
+func (e *EzAuth) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return e.Handler.RequirePermission(permission)
+}
+
 // GetUserID retrieves the user ID from the request context.
 func (e *EzAuth) GetUserID(ctx context.Context) (string, error) {
 	return handler.GetUserID(ctx)
 }
+
+// GetClaims retrieves the verified access token claims from the request context.
+func (e *EzAuth) GetClaims(ctx context.Context) (*service.Claims, error) {
+	return handler.GetClaims(ctx)
+}
+
+// GetUser retrieves the authenticated user, loading it at most once per request.
+func (e *EzAuth) GetUser(ctx context.Context) (*models.User, error) {
+	return handler.GetUser(ctx)
+}
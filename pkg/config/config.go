@@ -58,16 +58,113 @@ type SMTP struct {
 	From     string `json:"from" env:"SMTP_FROM"`
 }
 
+// JWT defines the settings for signing access tokens.
+// Algorithm is one of HS256, RS256, ES256 or EdDSA. HS256 signs with JWTSecret,
+// the asymmetric algorithms sign with the PEM private key at PrivateKeyFile.
+// If PrivateKeyFile is set but does not exist, a key is generated and written there on first start.
+// If it is empty, an ephemeral key is generated in memory.
+// PreviousSecrets and VerifyKeyFiles (PEM files or glob patterns) hold retired keys
+// that are still accepted for verification but never used for signing.
+type JWT struct {
+	Algorithm         string   `json:"algorithm" env:"JWT_ALGORITHM" default:"HS256"`
+	PrivateKeyFile    string   `json:"private_key_file" env:"JWT_PRIVATE_KEY_FILE"`
+	PreviousSecrets   []string `json:"-" env:"JWT_PREVIOUS_SECRETS"`
+	VerifyKeyFiles    []string `json:"verify_key_files" env:"JWT_VERIFY_KEY_FILES"`
+	Issuer            string   `json:"issuer" env:"JWT_ISSUER"`
+	Audience          []string `json:"audience" env:"JWT_AUDIENCE"`
+	AppMetadataClaims []string `json:"app_metadata_claims" env:"JWT_APP_METADATA_CLAIMS"`
+}
+
+// Token defines the lifetime of each token type.
+// RecentLoginTTL is how long after logging in a session may change the account email without the current password.
+type Token struct {
+	AccessTTL              time.Duration `json:"access_ttl" env:"TOKEN_ACCESS_TTL" default:"1h"`
+	RefreshTTL             time.Duration `json:"refresh_ttl" env:"TOKEN_REFRESH_TTL" default:"720h"`
+	PasswordResetTTL       time.Duration `json:"password_reset_ttl" env:"TOKEN_PASSWORD_RESET_TTL" default:"1h"`
+	MagicLinkTTL           time.Duration `json:"magic_link_ttl" env:"TOKEN_MAGIC_LINK_TTL" default:"15m"`
+	EmailVerificationTTL   time.Duration `json:"email_verification_ttl" env:"TOKEN_EMAIL_VERIFICATION_TTL" default:"24h"`
+	EmailChangeTTL         time.Duration `json:"email_change_ttl" env:"TOKEN_EMAIL_CHANGE_TTL" default:"24h"`
+	EmailRevertTTL         time.Duration `json:"email_revert_ttl" env:"TOKEN_EMAIL_REVERT_TTL" default:"168h"`
+	RecentLoginTTL         time.Duration `json:"recent_login_ttl" env:"TOKEN_RECENT_LOGIN_TTL" default:"5m"`
+	RevocationSyncInterval time.Duration `json:"revocation_sync_interval" env:"TOKEN_REVOCATION_SYNC_INTERVAL" default:"30s"`
+}
+
+// Introspection defines who may call the token introspection and revocation endpoints:
+// a client authenticating with ClientID and ClientSecret, or a user with AdminRole.
+// AdminRole is unset by default, so only clients may call them.
+type Introspection struct {
+	ClientID     string `json:"client_id" env:"INTROSPECTION_CLIENT_ID"`
+	ClientSecret string `json:"-" env:"INTROSPECTION_CLIENT_SECRET"`
+	AdminRole    string `json:"admin_role" env:"INTROSPECTION_ADMIN_ROLE"`
+}
+
+// Cookie defines the cookie session mode for browser apps.
+// When Enabled, the endpoints issuing tokens set them in HttpOnly cookies instead of the response body,
+// and requests authenticated with the access token cookie must carry a CSRF token.
+// Insecure drops the Secure attribute, for development over plain HTTP.
+type Cookie struct {
+	Enabled  bool   `json:"enabled" env:"COOKIE_ENABLED" default:"false"`
+	Domain   string `json:"domain" env:"COOKIE_DOMAIN"`
+	Path     string `json:"path" env:"COOKIE_PATH" default:"/"`
+	SameSite string `json:"same_site" env:"COOKIE_SAME_SITE" default:"lax"`
+	Insecure bool   `json:"insecure" env:"COOKIE_INSECURE" default:"false"`
+}
+
+// Activity defines how user activity is written to users.last_active_at.
+// Activity of a user is recorded at most once per Interval, and recorded activity
+// is written in batches once per FlushInterval.
+// Users with AdminRole may list the inactive users; it is unset by default, which leaves the route out.
+type Activity struct {
+	Interval      time.Duration `json:"interval" env:"ACTIVITY_INTERVAL" default:"5m"`
+	FlushInterval time.Duration `json:"flush_interval" env:"ACTIVITY_FLUSH_INTERVAL" default:"30s"`
+	AdminRole     string        `json:"admin_role" env:"ACTIVITY_ADMIN_ROLE"`
+}
+
+// Password defines the policy passwords must meet when they are set.
+type Password struct {
+	MinLength int `json:"min_length" env:"PASSWORD_MIN_LENGTH" default:"8"`
+}
+
+// Deletion defines how deleted accounts are kept before being purged.
+// A deleted account can be restored within GracePeriod, and accounts past it are purged every PurgeInterval.
+type Deletion struct {
+	GracePeriod   time.Duration `json:"grace_period" env:"DELETION_GRACE_PERIOD" default:"720h"`
+	PurgeInterval time.Duration `json:"purge_interval" env:"DELETION_PURGE_INTERVAL" default:"1h"`
+}
+
+// Features turns off auth methods and routes, which are all enabled by default.
+// DisableRegistration also keeps passwordless and OAuth2 logins from creating accounts,
+// and DisablePasswordLogin also removes /register, which creates password accounts.
+// RequireEmailVerification rejects password logins of users who have not verified their email.
+type Features struct {
+	DisableRegistration  bool `json:"disable_registration" env:"DISABLE_REGISTRATION" default:"false"`
+	DisablePasswordLogin bool `json:"disable_password_login" env:"DISABLE_PASSWORD_LOGIN" default:"false"`
+	DisablePasswordReset bool `json:"disable_password_reset" env:"DISABLE_PASSWORD_RESET" default:"false"`
+	DisablePasswordless  bool `json:"disable_passwordless" env:"DISABLE_PASSWORDLESS" default:"false"`
+	DisableOAuth2        bool `json:"disable_oauth2" env:"DISABLE_OAUTH2" default:"false"`
+	DisableSwagger       bool `json:"disable_swagger" env:"DISABLE_SWAGGER" default:"false"`
+
+	RequireEmailVerification bool `json:"require_email_verification" env:"REQUIRE_EMAIL_VERIFICATION" default:"false"`
+}
+
 // Config defines the overall configuration for ezauth.
 type Config struct {
-	Addr      string        `json:"addr" env:"ADDR" default:":8080"`
-	BaseURL   string        `json:"base_url" env:"BASE_URL" default:"http://localhost:8080"`
-	Debug     bool          `json:"debug" env:"DEBUG" default:"false"`
-	DB        Database      `json:"db"`
-	JWTSecret string        `json:"jwt_secret" env:"JWT_SECRET" required:"true"`
-	OAuth2    OAuth2        `json:"oauth2"`
-	SMTP      SMTP          `json:"smtp"`
-	TimeOut   time.Duration `json:"timeout" env:"TIMEOUT" default:"30s"`
+	Addr          string        `json:"addr" env:"ADDR" default:":8080"`
+	BaseURL       string        `json:"base_url" env:"BASE_URL" default:"http://localhost:8080"`
+	Debug         bool          `json:"debug" env:"DEBUG" default:"false"`
+	DB            Database      `json:"db"`
+	JWTSecret     string        `json:"jwt_secret" env:"JWT_SECRET" required:"true"`
+	JWT           JWT           `json:"jwt"`
+	Token         Token         `json:"token"`
+	Introspection Introspection `json:"introspection"`
+	Cookie        Cookie        `json:"cookie"`
+	Activity      Activity      `json:"activity"`
+	Password      Password      `json:"password"`
+	Deletion      Deletion      `json:"deletion"`
+	Features      Features      `json:"features"`
+	OAuth2        OAuth2        `json:"oauth2"`
+	SMTP          SMTP          `json:"smtp"`
+	TimeOut       time.Duration `json:"timeout" env:"TIMEOUT" default:"30s"`
 }
 
 // LoadConfig loads the configuration from environment variables.
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig_RequiredJWTSecret(t *testing.T) {
@@ -28,3 +29,25 @@ func TestLoadConfig_Success(t *testing.T) {
 		t.Errorf("expected JWTSecret to be 'super-secret', got '%s'", cfg.JWTSecret)
 	}
 }
+
+func TestLoadConfig_TokenLifetimes(t *testing.T) {
+	os.Setenv("EZAUTH_JWT_SECRET", "super-secret")
+	os.Setenv("EZAUTH_TOKEN_REFRESH_TTL", "2160h")
+	defer os.Unsetenv("EZAUTH_JWT_SECRET")
+	defer os.Unsetenv("EZAUTH_TOKEN_REFRESH_TTL")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Token.RefreshTTL != 2160*time.Hour {
+		t.Errorf("expected RefreshTTL to be 2160h, got %v", cfg.Token.RefreshTTL)
+	}
+	if cfg.Token.AccessTTL != time.Hour {
+		t.Errorf("expected default AccessTTL to be 1h, got %v", cfg.Token.AccessTTL)
+	}
+	if cfg.Token.MagicLinkTTL != 15*time.Minute {
+		t.Errorf("expected default MagicLinkTTL to be 15m, got %v", cfg.Token.MagicLinkTTL)
+	}
+}
@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+// sqliteHashTokens replaces the token values with their hex encoded SHA-256 digests, like
+// repository.HashToken does. SQLite has no built-in SHA-256 function, so the rows are rehashed in Go.
+var sqliteHashTokens = goose.NewGoMigration(20261017100000,
+	&goose.GoFunc{RunTx: sqliteHashTokensUp},
+	&goose.GoFunc{RunTx: sqliteHashTokensDown},
+)
+
+func sqliteHashTokensUp(ctx context.Context, tx *sql.Tx) error {
+	for _, table := range []string{"tokens", "passwordless_tokens"} {
+		if err := rehashTokens(ctx, tx, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteHashTokensDown drops the outstanding tokens, since digests cannot be reversed.
+func sqliteHashTokensDown(ctx context.Context, tx *sql.Tx) error {
+	for _, table := range []string{"tokens", "passwordless_tokens"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func rehashTokens(ctx context.Context, tx *sql.Tx, table string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id, token FROM "+table)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", table, err)
+	}
+	tokens := map[string]string{}
+	for rows.Next() {
+		var id, token string
+		if err := rows.Scan(&id, &token); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		tokens[id] = token
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list %s: %w", table, err)
+	}
+
+	for id, token := range tokens {
+		sum := sha256.Sum256([]byte(token))
+		if _, err := tx.ExecContext(ctx, "UPDATE "+table+" SET token = ? WHERE id = ?", hex.EncodeToString(sum[:]), id); err != nil {
+			return fmt.Errorf("failed to hash %s: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package migrations
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"fmt"
@@ -23,22 +24,40 @@ const (
 //go:embed postgres sqlite mysql
 var embedMigrations embed.FS
 
-type migrationFunc func(db *sql.DB, dir string, opts ...goose.OptionsFunc) error
+// goMigrations holds the migrations of each dialect that cannot be written in SQL.
+// They are given to the goose provider of their dialect only, since a version may also
+// be used by the SQL migrations of the other dialects.
+var goMigrations = map[string][]*goose.Migration{
+	DialectSqlite: {sqliteHashTokens},
+}
+
+type migrationFunc func(ctx context.Context, provider *goose.Provider) error
 
 func MigrateUp(dsn, dialect string) error {
-	return runMigration(dsn, dialect, goose.Up, "up")
+	return runMigration(dsn, dialect, migrateUp, "up")
 }
 
 func MigrateDown(dsn, dialect string) error {
-	return runMigration(dsn, dialect, goose.Down, "down")
+	return runMigration(dsn, dialect, migrateDown, "down")
 }
 
 func MigrateUpWithDBConn(db *sql.DB, dialect string) error {
-	return execGooseMigration(db, dialect, goose.Up, "up")
+	return execGooseMigration(db, dialect, migrateUp, "up")
 }
 
 func MigrateDownWithDBConn(db *sql.DB, dialect string) error {
-	return execGooseMigration(db, dialect, goose.Down, "down")
+	return execGooseMigration(db, dialect, migrateDown, "down")
+}
+
+func migrateUp(ctx context.Context, provider *goose.Provider) error {
+	_, err := provider.Up(ctx)
+	return err
+}
+
+// migrateDown rolls back the most recent migration.
+func migrateDown(ctx context.Context, provider *goose.Provider) error {
+	_, err := provider.Down(ctx)
+	return err
 }
 
 func getMigrationSubDir(dialect string) (string, error) {
@@ -48,8 +67,7 @@ func getMigrationSubDir(dialect string) (string, error) {
 		migrationSubDir = "postgres"
 	case DialectMysql:
 		migrationSubDir = "mysql"
-	case DialectSqlite, "sqlite":
-		dialect = DialectSqlite
+	case DialectSqlite:
 		migrationSubDir = "sqlite"
 	default:
 		return "", fmt.Errorf("unknown dialect: %s", dialect)
@@ -80,6 +98,9 @@ func runMigration(dsn, dialect string, command migrationFunc, action string) err
 }
 
 func execGooseMigration(db *sql.DB, dialect string, command migrationFunc, action string) error {
+	if dialect == "sqlite" {
+		dialect = DialectSqlite
+	}
 	migrationSubDir, err := getMigrationSubDir(dialect)
 	if err != nil {
 		xlog.Error("Failed to get migration dir")
@@ -91,14 +112,16 @@ func execGooseMigration(db *sql.DB, dialect string, command migrationFunc, actio
 		return err
 	}
 
-	if err := goose.SetDialect(dialect); err != nil {
-		return fmt.Errorf("failed to set dialect: %w", err)
+	provider, err := goose.NewProvider(goose.Dialect(dialect), db, rootFS,
+		goose.WithDisableGlobalRegistry(true),
+		goose.WithGoMigrations(goMigrations[dialect]...),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create migration provider: %w", err)
 	}
 
-	goose.SetBaseFS(rootFS)
-
 	xlog.Info(fmt.Sprintf("running migrations %s", action), "dialect", dialect, "folder", migrationSubDir)
-	if err := command(db, "."); err != nil {
+	if err := command(context.Background(), provider); err != nil {
 		return fmt.Errorf("failed to run migrations %s: %w", action, err)
 	}
 
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+)
+
+func TestSqliteHashTokens(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open(DialectSqlite, ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	rootFS, err := getRootFS("sqlite")
+	if err != nil {
+		t.Fatalf("getRootFS() unexpected error: %v", err)
+	}
+	provider, err := goose.NewProvider(goose.DialectSQLite3, db, rootFS,
+		goose.WithDisableGlobalRegistry(true),
+		goose.WithGoMigrations(goMigrations[DialectSqlite]...),
+	)
+	if err != nil {
+		t.Fatalf("NewProvider() unexpected error: %v", err)
+	}
+	if _, err := provider.UpTo(ctx, 20261017090000); err != nil {
+		t.Fatalf("UpTo() unexpected error: %v", err)
+	}
+
+	// Tokens issued before the migration are stored in plaintext
+	for _, query := range []string{
+		"INSERT INTO users (id, email) VALUES ('user-1', 'hash@example.com')",
+		"INSERT INTO tokens (id, user_id, token, token_type, expires_at) VALUES ('token-1', 'user-1', 'refresh-value', 'refresh', '2099-01-01 00:00:00')",
+		"INSERT INTO passwordless_tokens (id, email, token, expires_at) VALUES ('magic-1', 'hash@example.com', 'magic-value', '2099-01-01 00:00:00')",
+	} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			t.Fatalf("failed to insert test data: %v", err)
+		}
+	}
+
+	if err := MigrateUpWithDBConn(db, DialectSqlite); err != nil {
+		t.Fatalf("MigrateUpWithDBConn() unexpected error: %v", err)
+	}
+
+	for table, value := range map[string]string{"tokens": "refresh-value", "passwordless_tokens": "magic-value"} {
+		sum := sha256.Sum256([]byte(value))
+		var token string
+		if err := db.QueryRowContext(ctx, "SELECT token FROM "+table).Scan(&token); err != nil {
+			t.Fatalf("failed to read %s: %v", table, err)
+		}
+		if token != hex.EncodeToString(sum[:]) {
+			t.Errorf("expected %s to hold the digest of the token, got %q", table, token)
+		}
+	}
+
+	if _, err := provider.DownTo(ctx, 20261017090000); err != nil {
+		t.Fatalf("DownTo() unexpected error: %v", err)
+	}
+	for _, table := range []string{"tokens", "passwordless_tokens"} {
+		var count int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&count); err != nil {
+			t.Fatalf("failed to count %s: %v", table, err)
+		}
+		if count != 0 {
+			t.Errorf("expected %s to be emptied on rollback, got %d rows", table, count)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Token values are stored as hex encoded SHA-256 digests
+UPDATE tokens SET token = SHA2(token, 256);
+
+UPDATE passwordless_tokens SET token = SHA2(token, 256);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Digests cannot be reversed, outstanding tokens are dropped instead
+DELETE FROM tokens;
+
+DELETE FROM passwordless_tokens;
+-- +goose StatementEnd
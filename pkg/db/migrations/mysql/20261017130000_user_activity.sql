@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Speeds up listing inactive users
+CREATE INDEX idx_users_last_active_at ON users(last_active_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_users_last_active_at ON users;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Accounts pending deletion, purged once the grace period is over
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NULL;
+CREATE INDEX idx_users_deleted_at ON users(deleted_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_users_deleted_at ON users;
+ALTER TABLE users DROP COLUMN deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tokens ADD COLUMN family_id VARCHAR(64);
+
+CREATE INDEX idx_tokens_family_id ON tokens(family_id);
+
+COMMENT ON COLUMN tokens.family_id IS 'Refresh tokens issued from the same login share a family id';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tokens_family_id;
+
+ALTER TABLE tokens DROP COLUMN family_id;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Token values are stored as hex encoded SHA-256 digests
+UPDATE tokens SET token = encode(sha256(token::bytea), 'hex');
+
+UPDATE passwordless_tokens SET token = encode(sha256(token::bytea), 'hex');
+
+COMMENT ON COLUMN tokens.token IS 'Hex encoded SHA-256 digest of the token value';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Digests cannot be reversed, outstanding tokens are dropped instead
+DELETE FROM tokens;
+
+DELETE FROM passwordless_tokens;
+-- +goose StatementEnd
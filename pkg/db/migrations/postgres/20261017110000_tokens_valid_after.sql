@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN users.tokens_valid_after IS 'Access tokens issued to the user before this time are rejected';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN tokens_valid_after;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_users_last_active_at ON users(last_active_at);
+
+COMMENT ON COLUMN users.last_active_at IS 'Last login, token refresh or authenticated request, written at most once per activity interval';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_last_active_at;
+-- +goose StatementEnd
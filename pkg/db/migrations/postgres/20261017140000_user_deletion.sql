@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX idx_users_deleted_at ON users(deleted_at);
+
+COMMENT ON COLUMN users.deleted_at IS 'Set when the account is deleted; it can be restored until the grace period is over, then it is purged';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
+-- +goose StatementEnd
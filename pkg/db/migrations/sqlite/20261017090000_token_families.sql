@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Refresh tokens issued from the same login share a family id
+ALTER TABLE tokens ADD COLUMN family_id TEXT;
+
+CREATE INDEX idx_tokens_family_id ON tokens(family_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tokens_family_id;
+
+ALTER TABLE tokens DROP COLUMN family_id;
+-- +goose StatementEnd
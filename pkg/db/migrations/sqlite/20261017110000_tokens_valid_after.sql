@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Access tokens issued to a user before this time are rejected
+ALTER TABLE users ADD COLUMN tokens_valid_after DATETIME;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN tokens_valid_after;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Accounts pending deletion, purged once the grace period is over
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+CREATE INDEX idx_users_deleted_at ON users(deleted_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
+-- +goose StatementEnd
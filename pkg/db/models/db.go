@@ -4,6 +4,9 @@ const (
 	TableUser             = "users"
 	TableToken            = "tokens"
 	TablePasswordlessToken = "passwordless_tokens"
+	TableRole             = "roles"
+	TablePermission       = "permissions"
+	TableRolePermission   = "role_permissions"
 	ColumnEmail           = "email"
 	ColumnPasswordHash  = "password_hash"
 	ColumnProvider      = "provider"
@@ -18,6 +21,8 @@ const (
 	ColumnTimezone      = "timezone"
 	ColumnEmailVerifiedAt = "email_verified_at"
 	ColumnRoles         = "roles"
+	ColumnTokensValidAfter = "tokens_valid_after"
+	ColumnDeletedAt       = "deleted_at"
 	ColumnCreatedAt     = "created_at"
 	ColumnUpdatedAt     = "updated_at"
 	ColumnUserID        = "user_id"
@@ -26,4 +31,9 @@ const (
 	ColumnExpiresAt     = "expires_at"
 	ColumnRevoked       = "revoked"
 	ColumnMetadata      = "metadata"
+	ColumnFamilyID      = "family_id"
+	ColumnName          = "name"
+	ColumnDescription   = "description"
+	ColumnRoleID        = "role_id"
+	ColumnPermissionID  = "permission_id"
 )
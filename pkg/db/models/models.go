@@ -5,6 +5,8 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -39,6 +41,71 @@ func (j *JSONMap) Scan(value any) error {
 	return json.Unmarshal(bytes, j)
 }
 
+// Roles is the list of roles of a user.
+// It is stored as a comma-separated string and encoded as a JSON array.
+type Roles []string
+
+// ParseRoles parses a comma-separated list of roles, dropping blanks and duplicates.
+func ParseRoles(s string) Roles {
+	roles := Roles{}
+	for _, role := range strings.Split(s, ",") {
+		if role = strings.TrimSpace(role); role != "" && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Has reports whether role is in the list.
+func (r Roles) Has(role string) bool {
+	return slices.Contains(r, role)
+}
+
+// HasAny reports whether any of roles is in the list.
+func (r Roles) HasAny(roles ...string) bool {
+	return slices.ContainsFunc(roles, r.Has)
+}
+
+// String returns the comma-separated list of roles.
+func (r Roles) String() string {
+	return strings.Join(r, ",")
+}
+
+// Value implements the driver.Valuer interface
+func (r Roles) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (r *Roles) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*r = Roles{}
+	case []byte:
+		*r = ParseRoles(string(v))
+	case string:
+		*r = ParseRoles(v)
+	default:
+		return errors.New("type assertion to []byte or string failed")
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts a JSON array or a comma-separated string.
+func (r *Roles) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*r = ParseRoles(s)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*r = ParseRoles(strings.Join(list, ","))
+	return nil
+}
+
 // User represents a user in the system.
 type User struct {
 	ID            string    `db:"id" json:"id"`
@@ -55,16 +122,22 @@ type User struct {
 	Locale        string     `db:"locale" json:"locale"`
 	Timezone      string     `db:"timezone" json:"timezone"`
 	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at,omitempty"`
-	Roles         string     `db:"roles" json:"roles"`
+	Roles         Roles      `db:"roles" json:"roles"`
+	TokensValidAfter *time.Time `db:"tokens_valid_after" json:"-"` // access tokens issued before are revoked
+	DeletedAt     *time.Time `db:"deleted_at" json:"deleted_at,omitempty"` // pending deletion, purged after the grace period
 	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
 }
 
 const (
-	TokenTypeAccess        = "access"
-	TokenTypeRefresh       = "refresh"
-	TokenTypePasswordless  = "passwordless"
-	TokenTypePasswordReset = "password_reset"
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypePasswordless      = "passwordless"
+	TokenTypePasswordReset     = "password_reset"
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypeEmailChange       = "email_change"
+	TokenTypeEmailRevert       = "email_revert"
+	TokenTypeAccountRestore    = "account_restore"
 )
 
 // Token represents an authentication or action token (e.g., refresh token, password reset token).
@@ -77,6 +150,7 @@ type Token struct {
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	Revoked   bool      `db:"revoked" json:"revoked"`
 	Metadata  JSONMap   `db:"metadata" json:"metadata"`
+	FamilyID  *string   `db:"family_id" json:"family_id,omitempty"` // shared by rotated refresh tokens
 }
 
 // PasswordlessToken represents a magic link token for passwordless login.
@@ -87,3 +161,20 @@ type PasswordlessToken struct {
 	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
+
+// Role is a named set of permissions.
+// Users have a role when its name is in their Roles.
+type Role struct {
+	ID          string    `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+// Permission is an action users may be allowed to perform, such as invoices:read.
+type Permission struct {
+	ID          string    `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"time"
 
 	"github.com/josuebrunel/ezauth/pkg/db/models"
 	"github.com/stephenafamo/bob"
@@ -132,7 +133,7 @@ func (q *PSQLQuerier) QueryUserUpdate(ctx context.Context, user *models.User) bo
 		qm = append(qm, um.Set(psql.Quote(models.ColumnEmailVerifiedAt).EQ(psql.Arg(user.EmailVerifiedAt))))
 	}
 
-	if user.Roles != "" {
+	if user.Roles != nil {
 		qm = append(qm, um.Set(psql.Quote(models.ColumnRoles).EQ(psql.Arg(user.Roles))))
 	}
 
@@ -149,6 +150,63 @@ func (q *PSQLQuerier) QueryUserCheckPasswordHash(ctx context.Context, email, pas
 	return psql.Select(sm.From(psql.Quote(models.TableUser)), sm.Where(psql.Quote(models.ColumnEmail).EQ(psql.Arg(email)).And(psql.Quote(models.ColumnPasswordHash).EQ(psql.Arg(passwordHash)))))
 }
 
+func (q *PSQLQuerier) QueryUserRevokeTokens(ctx context.Context, id string, before time.Time) bob.Query {
+	return psql.Update(
+		um.Table(psql.Quote(models.TableUser)),
+		um.SetCol(models.ColumnTokensValidAfter).ToArg(before),
+		um.Where(psql.Quote("id").EQ(psql.Arg(id))),
+	)
+}
+
+func (q *PSQLQuerier) QueryUserListTokensRevoked(ctx context.Context) bob.Query {
+	return psql.Select(sm.From(psql.Quote(models.TableUser)), sm.Where(psql.Quote(models.ColumnTokensValidAfter).IsNotNull()))
+}
+
+func (q *PSQLQuerier) QueryUserActivityUpdate(ctx context.Context, id string, at time.Time) bob.Query {
+	return psql.Update(
+		um.Table(psql.Quote(models.TableUser)),
+		um.SetCol(models.ColumnLastActiveAt).ToArg(at),
+		um.Where(psql.Quote("id").EQ(psql.Arg(id))),
+		um.Where(psql.Quote(models.ColumnLastActiveAt).IsNull().
+			Or(psql.Quote(models.ColumnLastActiveAt).LT(psql.Arg(at)))),
+	)
+}
+
+func (q *PSQLQuerier) QueryUserListInactive(ctx context.Context, before time.Time) bob.Query {
+	return psql.Select(
+		sm.From(psql.Quote(models.TableUser)),
+		sm.Where(psql.Quote(models.ColumnLastActiveAt).IsNull().
+			Or(psql.Quote(models.ColumnLastActiveAt).LT(psql.Arg(before)))),
+		sm.OrderBy(psql.Quote(models.ColumnLastActiveAt)).Asc().NullsFirst(),
+	)
+}
+
+func (q *PSQLQuerier) QueryUserSoftDelete(ctx context.Context, id string, at time.Time) bob.Query {
+	return psql.Update(
+		um.Table(psql.Quote(models.TableUser)),
+		um.SetCol(models.ColumnDeletedAt).ToArg(at),
+		um.Where(psql.Quote("id").EQ(psql.Arg(id))),
+		um.Where(psql.Quote(models.ColumnDeletedAt).IsNull()),
+	)
+}
+
+func (q *PSQLQuerier) QueryUserRestore(ctx context.Context, id string) bob.Query {
+	return psql.Update(
+		um.Table(psql.Quote(models.TableUser)),
+		um.SetCol(models.ColumnDeletedAt).ToArg(nil),
+		um.Where(psql.Quote("id").EQ(psql.Arg(id))),
+	)
+}
+
+func (q *PSQLQuerier) QueryUserListDeleted(ctx context.Context, before time.Time) bob.Query {
+	return psql.Select(
+		sm.From(psql.Quote(models.TableUser)),
+		sm.Where(psql.Quote(models.ColumnDeletedAt).IsNotNull()),
+		sm.Where(psql.Quote(models.ColumnDeletedAt).LT(psql.Arg(before))),
+		sm.OrderBy(psql.Quote(models.ColumnDeletedAt)).Asc(),
+	)
+}
+
 func (q *PSQLQuerier) QueryUserDelete(ctx context.Context, id string) bob.Query {
 	return psql.Delete(dm.From(psql.Quote(models.TableUser)), dm.Where(psql.Quote("id").EQ(psql.Arg(id))))
 }
@@ -163,6 +221,7 @@ func (q *PSQLQuerier) QueryTokenInsert(ctx context.Context, token *models.Token)
 			models.ColumnCreatedAt,
 			models.ColumnRevoked,
 			models.ColumnMetadata,
+			models.ColumnFamilyID,
 		),
 		im.Values(
 			psql.Arg(token.UserID),
@@ -172,6 +231,7 @@ func (q *PSQLQuerier) QueryTokenInsert(ctx context.Context, token *models.Token)
 			psql.Arg(token.CreatedAt),
 			psql.Arg(token.Revoked),
 			psql.Arg(token.Metadata),
+			psql.Arg(token.FamilyID),
 		),
 	)
 }
@@ -189,6 +249,45 @@ func (q *PSQLQuerier) QueryTokenRevoke(ctx context.Context, id string) bob.Query
 		um.Table(psql.Quote(models.TableToken)),
 		um.SetCol(psql.Quote(models.ColumnRevoked).String()).To(true),
 		um.Where(psql.Quote("id").EQ(psql.Arg(id))),
+		um.Where(psql.Quote(models.ColumnRevoked).EQ(psql.Arg(false))),
+	)
+}
+
+func (q *PSQLQuerier) QueryTokenRevokeFamily(ctx context.Context, familyID string) bob.Query {
+	return psql.Update(
+		um.Table(psql.Quote(models.TableToken)),
+		um.SetCol(models.ColumnRevoked).To(true),
+		um.Where(psql.Quote(models.ColumnFamilyID).EQ(psql.Arg(familyID))),
+		um.Where(psql.Quote(models.ColumnRevoked).EQ(psql.Arg(false))),
+	)
+}
+
+func (q *PSQLQuerier) QueryTokenRevokeByUser(ctx context.Context, userID, tokenType string) bob.Query {
+	return psql.Update(
+		um.Table(psql.Quote(models.TableToken)),
+		um.SetCol(models.ColumnRevoked).To(true),
+		um.Where(psql.Quote(models.ColumnUserID).EQ(psql.Arg(userID))),
+		um.Where(psql.Quote(models.ColumnTokenType).EQ(psql.Arg(tokenType))),
+		um.Where(psql.Quote(models.ColumnRevoked).EQ(psql.Arg(false))),
+	)
+}
+
+func (q *PSQLQuerier) QueryTokenListActive(ctx context.Context, userID, tokenType string) bob.Query {
+	return psql.Select(
+		sm.From(psql.Quote(models.TableToken)),
+		sm.Where(psql.Quote(models.ColumnUserID).EQ(psql.Arg(userID))),
+		sm.Where(psql.Quote(models.ColumnTokenType).EQ(psql.Arg(tokenType))),
+		sm.Where(psql.Quote(models.ColumnRevoked).EQ(psql.Arg(false))),
+		sm.Where(psql.Quote(models.ColumnExpiresAt).GT(psql.Arg(time.Now().UTC()))),
+	)
+}
+
+func (q *PSQLQuerier) QueryTokenListRevoked(ctx context.Context, tokenType string) bob.Query {
+	return psql.Select(
+		sm.From(psql.Quote(models.TableToken)),
+		sm.Where(psql.Quote(models.ColumnTokenType).EQ(psql.Arg(tokenType))),
+		sm.Where(psql.Quote(models.ColumnRevoked).EQ(psql.Arg(true))),
+		sm.Where(psql.Quote(models.ColumnExpiresAt).GT(psql.Arg(time.Now().UTC()))),
 	)
 }
 
@@ -196,6 +295,10 @@ func (q *PSQLQuerier) QueryTokenDelete(ctx context.Context, id string) bob.Query
 	return psql.Delete(dm.From(psql.Quote(models.TableToken)), dm.Where(psql.Quote("id").EQ(psql.Arg(id))))
 }
 
+func (q *PSQLQuerier) QueryTokenDeleteByUser(ctx context.Context, userID string) bob.Query {
+	return psql.Delete(dm.From(psql.Quote(models.TableToken)), dm.Where(psql.Quote(models.ColumnUserID).EQ(psql.Arg(userID))))
+}
+
 func (q *PSQLQuerier) QueryPasswordlessTokenInsert(ctx context.Context, token *models.PasswordlessToken) bob.Query {
 	return psql.Insert(
 		im.Into(psql.Quote(models.TablePasswordlessToken),
@@ -227,3 +330,123 @@ func (q *PSQLQuerier) QueryPasswordlessTokenDelete(ctx context.Context, token st
 		dm.Where(psql.Quote(models.ColumnToken).EQ(psql.Arg(token))),
 	)
 }
+
+func (q *PSQLQuerier) QueryPasswordlessTokenDeleteByEmail(ctx context.Context, email string) bob.Query {
+	return psql.Delete(
+		dm.From(psql.Quote(models.TablePasswordlessToken)),
+		dm.Where(psql.Quote(models.ColumnEmail).EQ(psql.Arg(email))),
+	)
+}
+
+func (q *PSQLQuerier) QueryRoleInsert(ctx context.Context, role *models.Role) bob.Query {
+	return psql.Insert(
+		im.Into(psql.Quote(models.TableRole),
+			models.ColumnName,
+			models.ColumnDescription,
+			models.ColumnCreatedAt,
+		),
+		im.Values(
+			psql.Arg(role.Name),
+			psql.Arg(role.Description),
+			psql.Arg(role.CreatedAt),
+		),
+		im.Returning("*"),
+	)
+}
+
+func (q *PSQLQuerier) QueryRoleGetByName(ctx context.Context, name string) bob.Query {
+	return psql.Select(sm.From(psql.Quote(models.TableRole)), sm.Where(psql.Quote(models.ColumnName).EQ(psql.Arg(name))))
+}
+
+func (q *PSQLQuerier) QueryRoleList(ctx context.Context) bob.Query {
+	return psql.Select(sm.From(psql.Quote(models.TableRole)), sm.OrderBy(psql.Quote(models.ColumnName)))
+}
+
+func (q *PSQLQuerier) QueryRoleDelete(ctx context.Context, id string) bob.Query {
+	return psql.Delete(dm.From(psql.Quote(models.TableRole)), dm.Where(psql.Quote("id").EQ(psql.Arg(id))))
+}
+
+func (q *PSQLQuerier) QueryPermissionInsert(ctx context.Context, permission *models.Permission) bob.Query {
+	return psql.Insert(
+		im.Into(psql.Quote(models.TablePermission),
+			models.ColumnName,
+			models.ColumnDescription,
+			models.ColumnCreatedAt,
+		),
+		im.Values(
+			psql.Arg(permission.Name),
+			psql.Arg(permission.Description),
+			psql.Arg(permission.CreatedAt),
+		),
+		im.Returning("*"),
+	)
+}
+
+func (q *PSQLQuerier) QueryPermissionGetByName(ctx context.Context, name string) bob.Query {
+	return psql.Select(sm.From(psql.Quote(models.TablePermission)), sm.Where(psql.Quote(models.ColumnName).EQ(psql.Arg(name))))
+}
+
+func (q *PSQLQuerier) QueryPermissionList(ctx context.Context) bob.Query {
+	return psql.Select(sm.From(psql.Quote(models.TablePermission)), sm.OrderBy(psql.Quote(models.ColumnName)))
+}
+
+func (q *PSQLQuerier) QueryPermissionListByRoles(ctx context.Context, roles []string) bob.Query {
+	names := make([]any, len(roles))
+	for i, role := range roles {
+		names[i] = role
+	}
+	return psql.Select(
+		sm.Distinct(),
+		sm.Columns(
+			psql.Quote(models.TablePermission, "id"),
+			psql.Quote(models.TablePermission, models.ColumnName),
+			psql.Quote(models.TablePermission, models.ColumnDescription),
+			psql.Quote(models.TablePermission, models.ColumnCreatedAt),
+		),
+		sm.From(psql.Quote(models.TablePermission)),
+		sm.InnerJoin(psql.Quote(models.TableRolePermission)).OnEQ(
+			psql.Quote(models.TableRolePermission, models.ColumnPermissionID),
+			psql.Quote(models.TablePermission, "id"),
+		),
+		sm.InnerJoin(psql.Quote(models.TableRole)).OnEQ(
+			psql.Quote(models.TableRole, "id"),
+			psql.Quote(models.TableRolePermission, models.ColumnRoleID),
+		),
+		sm.Where(psql.Quote(models.TableRole, models.ColumnName).In(psql.Arg(names...))),
+		sm.OrderBy(psql.Quote(models.TablePermission, models.ColumnName)),
+	)
+}
+
+func (q *PSQLQuerier) QueryPermissionDelete(ctx context.Context, id string) bob.Query {
+	return psql.Delete(dm.From(psql.Quote(models.TablePermission)), dm.Where(psql.Quote("id").EQ(psql.Arg(id))))
+}
+
+func (q *PSQLQuerier) QueryRolePermissionInsert(ctx context.Context, roleID, permissionID string) bob.Query {
+	return psql.Insert(
+		im.Into(psql.Quote(models.TableRolePermission), models.ColumnRoleID, models.ColumnPermissionID),
+		im.Values(psql.Arg(roleID), psql.Arg(permissionID)),
+		im.OnConflict(models.ColumnRoleID, models.ColumnPermissionID).DoNothing(),
+	)
+}
+
+func (q *PSQLQuerier) QueryRolePermissionDelete(ctx context.Context, roleID, permissionID string) bob.Query {
+	return psql.Delete(
+		dm.From(psql.Quote(models.TableRolePermission)),
+		dm.Where(psql.Quote(models.ColumnRoleID).EQ(psql.Arg(roleID))),
+		dm.Where(psql.Quote(models.ColumnPermissionID).EQ(psql.Arg(permissionID))),
+	)
+}
+
+func (q *PSQLQuerier) QueryRolePermissionDeleteByRole(ctx context.Context, roleID string) bob.Query {
+	return psql.Delete(
+		dm.From(psql.Quote(models.TableRolePermission)),
+		dm.Where(psql.Quote(models.ColumnRoleID).EQ(psql.Arg(roleID))),
+	)
+}
+
+func (q *PSQLQuerier) QueryRolePermissionDeleteByPermission(ctx context.Context, permissionID string) bob.Query {
+	return psql.Delete(
+		dm.From(psql.Quote(models.TableRolePermission)),
+		dm.Where(psql.Quote(models.ColumnPermissionID).EQ(psql.Arg(permissionID))),
+	)
+}
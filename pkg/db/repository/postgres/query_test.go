@@ -100,6 +100,97 @@ func TestPSQLQuerier_UserOperations(t *testing.T) {
 		}
 	})
 
+	t.Run("RevokeTokens", func(t *testing.T) {
+		q := querier.QueryUserRevokeTokens(ctx, user.ID, now)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "UPDATE \"users\"") || !strings.Contains(sql, "\"tokens_valid_after\" = $1") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 2 || args[1] != user.ID {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("ActivityUpdate", func(t *testing.T) {
+		q := querier.QueryUserActivityUpdate(ctx, user.ID, now)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "UPDATE \"users\"") || !strings.Contains(sql, "\"last_active_at\" = $1") ||
+			!strings.Contains(sql, "\"last_active_at\" IS NULL") || !strings.Contains(sql, "\"last_active_at\" < $3") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 3 || args[1] != user.ID {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("ListInactive", func(t *testing.T) {
+		q := querier.QueryUserListInactive(ctx, now)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "\"last_active_at\" IS NULL") || !strings.Contains(sql, "ORDER BY \"last_active_at\" ASC NULLS FIRST") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 1 {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		q := querier.QueryUserSoftDelete(ctx, user.ID, now)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "UPDATE \"users\"") || !strings.Contains(sql, "\"deleted_at\" = $1") || !strings.Contains(sql, "\"deleted_at\" IS NULL") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 2 || args[1] != user.ID {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		q := querier.QueryUserRestore(ctx, user.ID)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "UPDATE \"users\"") || !strings.Contains(sql, "\"deleted_at\" = $1") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 2 || args[0] != nil || args[1] != user.ID {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("ListDeleted", func(t *testing.T) {
+		q := querier.QueryUserListDeleted(ctx, now)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "\"deleted_at\" IS NOT NULL") || !strings.Contains(sql, "\"deleted_at\" < $1") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 1 {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
 	t.Run("Delete", func(t *testing.T) {
 		q := querier.QueryUserDelete(ctx, user.ID)
 		sql, args, err := bob.Build(ctx, q)
@@ -180,6 +271,81 @@ func TestPSQLQuerier_TokenOperations(t *testing.T) {
 		}
 	})
 
+	t.Run("Revoke", func(t *testing.T) {
+		q := querier.QueryTokenRevoke(ctx, "token-123")
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "\"id\" = $1") || !strings.Contains(sql, "\"revoked\" = $2") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 2 || args[0] != "token-123" || args[1] != false {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("RevokeFamily", func(t *testing.T) {
+		q := querier.QueryTokenRevokeFamily(ctx, "family-123")
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "\"revoked\" = true") || !strings.Contains(sql, "\"family_id\" = $1") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 2 || args[0] != "family-123" {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("RevokeByUser", func(t *testing.T) {
+		q := querier.QueryTokenRevokeByUser(ctx, token.UserID, models.TokenTypeRefresh)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "UPDATE \"tokens\"") || !strings.Contains(sql, "\"user_id\" = $1") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 3 || args[0] != token.UserID || args[1] != models.TokenTypeRefresh {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("ListActive", func(t *testing.T) {
+		q := querier.QueryTokenListActive(ctx, token.UserID, models.TokenTypeRefresh)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "\"user_id\" = $1") || !strings.Contains(sql, "\"expires_at\" > $4") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 4 || args[0] != token.UserID || args[2] != false {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("ListRevoked", func(t *testing.T) {
+		q := querier.QueryTokenListRevoked(ctx, models.TokenTypeAccess)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "FROM \"tokens\"") || !strings.Contains(sql, "\"revoked\" = $2") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 3 || args[0] != models.TokenTypeAccess {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
 	t.Run("Delete", func(t *testing.T) {
 		q := querier.QueryTokenDelete(ctx, token.ID)
 		sql, args, err := bob.Build(ctx, q)
@@ -194,4 +360,83 @@ func TestPSQLQuerier_TokenOperations(t *testing.T) {
 			t.Errorf("unexpected args: %v", args)
 		}
 	})
+	t.Run("DeleteByUser", func(t *testing.T) {
+		q := querier.QueryTokenDeleteByUser(ctx, token.UserID)
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "DELETE FROM \"tokens\"") || !strings.Contains(sql, "\"user_id\" = $1") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 1 || args[0] != token.UserID {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+}
+
+func TestPSQLQuerier_PermissionOperations(t *testing.T) {
+	querier := &PSQLQuerier{}
+	ctx := context.Background()
+
+	t.Run("RoleInsert", func(t *testing.T) {
+		q := querier.QueryRoleInsert(ctx, &models.Role{Name: "billing", Description: "Billing team"})
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "INSERT INTO \"roles\"") || !strings.Contains(sql, "RETURNING *") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 3 || args[0] != "billing" {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("RolePermissionInsert", func(t *testing.T) {
+		q := querier.QueryRolePermissionInsert(ctx, "role-id", "permission-id")
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "INSERT INTO \"role_permissions\"") || !strings.Contains(sql, "DO NOTHING") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 2 || args[0] != "role-id" || args[1] != "permission-id" {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("RolePermissionDeleteByRole", func(t *testing.T) {
+		q := querier.QueryRolePermissionDeleteByRole(ctx, "role-id")
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "DELETE FROM \"role_permissions\"") || !strings.Contains(sql, "\"role_id\" = $1") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 1 || args[0] != "role-id" {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("PermissionListByRoles", func(t *testing.T) {
+		q := querier.QueryPermissionListByRoles(ctx, []string{"billing", "admin"})
+		sql, args, err := bob.Build(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to build query: %v", err)
+		}
+
+		if !strings.Contains(sql, "SELECT DISTINCT") || !strings.Contains(sql, "\"roles\".\"name\" IN ($1, $2)") {
+			t.Errorf("unexpected SQL: %s", sql)
+		}
+		if len(args) != 2 || args[0] != "billing" || args[1] != "admin" {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
 }
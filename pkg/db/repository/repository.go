@@ -3,7 +3,10 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"time"
 
 	"github.com/josuebrunel/ezauth/pkg/db/models"
 	"github.com/josuebrunel/ezauth/pkg/db/repository/postgres"
@@ -24,6 +27,13 @@ type UserQuerier interface {
 	QueryUserGetByID(ctx context.Context, id string) bob.Query
 	QueryUserGetByProvider(ctx context.Context, provider, providerID string) bob.Query
 	QueryUserUpdate(ctx context.Context, user *models.User) bob.Query
+	QueryUserRevokeTokens(ctx context.Context, id string, before time.Time) bob.Query
+	QueryUserListTokensRevoked(ctx context.Context) bob.Query
+	QueryUserActivityUpdate(ctx context.Context, id string, at time.Time) bob.Query
+	QueryUserListInactive(ctx context.Context, before time.Time) bob.Query
+	QueryUserSoftDelete(ctx context.Context, id string, at time.Time) bob.Query
+	QueryUserRestore(ctx context.Context, id string) bob.Query
+	QueryUserListDeleted(ctx context.Context, before time.Time) bob.Query
 	QueryUserDelete(ctx context.Context, id string) bob.Query
 }
 
@@ -32,19 +42,46 @@ type TokenQuerier interface {
 	QueryTokenGetByID(ctx context.Context, id string) bob.Query
 	QueryTokenGetByToken(ctx context.Context, token string) bob.Query
 	QueryTokenRevoke(ctx context.Context, id string) bob.Query
+	QueryTokenRevokeFamily(ctx context.Context, familyID string) bob.Query
+	QueryTokenRevokeByUser(ctx context.Context, userID, tokenType string) bob.Query
+	QueryTokenListActive(ctx context.Context, userID, tokenType string) bob.Query
+	QueryTokenListRevoked(ctx context.Context, tokenType string) bob.Query
 	QueryTokenDelete(ctx context.Context, id string) bob.Query
+	QueryTokenDeleteByUser(ctx context.Context, userID string) bob.Query
 }
 
 type PasswordlessQuerier interface {
 	QueryPasswordlessTokenInsert(ctx context.Context, token *models.PasswordlessToken) bob.Query
 	QueryPasswordlessTokenGetByToken(ctx context.Context, token string) bob.Query
 	QueryPasswordlessTokenDelete(ctx context.Context, token string) bob.Query
+	QueryPasswordlessTokenDeleteByEmail(ctx context.Context, email string) bob.Query
+}
+
+type RoleQuerier interface {
+	QueryRoleInsert(ctx context.Context, role *models.Role) bob.Query
+	QueryRoleGetByName(ctx context.Context, name string) bob.Query
+	QueryRoleList(ctx context.Context) bob.Query
+	QueryRoleDelete(ctx context.Context, id string) bob.Query
+	QueryRolePermissionInsert(ctx context.Context, roleID, permissionID string) bob.Query
+	QueryRolePermissionDelete(ctx context.Context, roleID, permissionID string) bob.Query
+	QueryRolePermissionDeleteByRole(ctx context.Context, roleID string) bob.Query
+	QueryRolePermissionDeleteByPermission(ctx context.Context, permissionID string) bob.Query
+}
+
+type PermissionQuerier interface {
+	QueryPermissionInsert(ctx context.Context, permission *models.Permission) bob.Query
+	QueryPermissionGetByName(ctx context.Context, name string) bob.Query
+	QueryPermissionList(ctx context.Context) bob.Query
+	QueryPermissionListByRoles(ctx context.Context, roles []string) bob.Query
+	QueryPermissionDelete(ctx context.Context, id string) bob.Query
 }
 
 type Querier interface {
 	UserQuerier
 	TokenQuerier
 	PasswordlessQuerier
+	RoleQuerier
+	PermissionQuerier
 }
 
 // Opts defines the options for opening a repository connection.
@@ -153,19 +190,130 @@ func (r Repository) UserUpdate(ctx context.Context, user *models.User) (*models.
 	return updatedUser, nil
 }
 
-// UserDelete deletes a user from the database.
-func (r Repository) UserDelete(ctx context.Context, id string) error {
-	query := r.QueryUserDelete(ctx, id)
+// UserRevokeTokens revokes every access token issued to a user before the given time.
+func (r Repository) UserRevokeTokens(ctx context.Context, id string, before time.Time) error {
+	query := r.QueryUserRevokeTokens(ctx, id, before)
+	if _, err := bob.Exec(ctx, r.bdb, query); err != nil {
+		xlog.Error("Failed to revoke user tokens", "error", err, "id", id)
+		return err
+	}
+	return nil
+}
+
+// UserListTokensRevoked retrieves the users whose access tokens were revoked with UserRevokeTokens.
+func (r Repository) UserListTokensRevoked(ctx context.Context) ([]*models.User, error) {
+	query := r.QueryUserListTokensRevoked(ctx)
+	users, err := bob.All(ctx, r.bdb, query, scan.StructMapper[*models.User]())
+	if err != nil {
+		xlog.Error("Failed to list users with revoked tokens", "error", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// UserActivityUpdate sets the last_active_at of each user in activity, in a single transaction.
+// A user's last_active_at is never moved backwards.
+func (r Repository) UserActivityUpdate(ctx context.Context, activity map[string]time.Time) error {
+	err := r.bdb.RunInTx(ctx, nil, func(ctx context.Context, exec bob.Executor) error {
+		for id, at := range activity {
+			if _, err := bob.Exec(ctx, exec, r.QueryUserActivityUpdate(ctx, id, at)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		xlog.Error("Failed to update user activity", "error", err, "users", len(activity))
+		return err
+	}
+	return nil
+}
+
+// UserListInactive retrieves the users not active since the given time, including those never active,
+// least recently active first.
+func (r Repository) UserListInactive(ctx context.Context, before time.Time) ([]*models.User, error) {
+	query := r.QueryUserListInactive(ctx, before)
+	users, err := bob.All(ctx, r.bdb, query, scan.StructMapper[*models.User]())
+	if err != nil {
+		xlog.Error("Failed to list inactive users", "error", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// UserSoftDelete marks a user as pending deletion at the given time.
+// A user already pending deletion keeps their deletion time.
+func (r Repository) UserSoftDelete(ctx context.Context, id string, at time.Time) error {
+	query := r.QueryUserSoftDelete(ctx, id, at)
+	if _, err := bob.Exec(ctx, r.bdb, query); err != nil {
+		xlog.Error("Failed to soft delete user", "error", err, "id", id)
+		return err
+	}
+	return nil
+}
+
+// UserRestore clears the pending deletion of a user.
+func (r Repository) UserRestore(ctx context.Context, id string) error {
+	query := r.QueryUserRestore(ctx, id)
 	if _, err := bob.Exec(ctx, r.bdb, query); err != nil {
+		xlog.Error("Failed to restore user", "error", err, "id", id)
+		return err
+	}
+	return nil
+}
+
+// UserListDeleted retrieves the users pending deletion since before the given time, oldest deletion first.
+func (r Repository) UserListDeleted(ctx context.Context, before time.Time) ([]*models.User, error) {
+	query := r.QueryUserListDeleted(ctx, before)
+	users, err := bob.All(ctx, r.bdb, query, scan.StructMapper[*models.User]())
+	if err != nil {
+		xlog.Error("Failed to list deleted users", "error", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// UserDelete deletes a user along with their tokens and magic links, in a single transaction.
+// Tokens are deleted explicitly since SQLite does not enforce the foreign key to users by default.
+func (r Repository) UserDelete(ctx context.Context, id string) error {
+	err := r.bdb.RunInTx(ctx, nil, func(ctx context.Context, exec bob.Executor) error {
+		user, err := bob.One(ctx, exec, r.QueryUserGetByID(ctx, id), scan.StructMapper[*models.User]())
+		if err != nil {
+			return err
+		}
+		if _, err := bob.Exec(ctx, exec, r.QueryTokenDeleteByUser(ctx, id)); err != nil {
+			return err
+		}
+		if _, err := bob.Exec(ctx, exec, r.QueryPasswordlessTokenDeleteByEmail(ctx, user.Email)); err != nil {
+			return err
+		}
+		_, err = bob.Exec(ctx, exec, r.QueryUserDelete(ctx, id))
+		return err
+	})
+	if err != nil {
 		xlog.Error("Failed to delete user", "error", err, "id", id)
 		return err
 	}
 	return nil
 }
 
+// HashToken returns the hex encoded SHA-256 digest of a token value.
+// Refresh, password reset and magic link tokens are only stored and looked up by their digest,
+// so a database leak does not yield working credentials.
+func HashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
 // PasswordlessTokenCreate creates a new passwordless token in the database.
+// Only the digest of the token value is stored; the returned token holds the digest.
+// Times are stored in UTC, which the expiry queries compare against.
 func (r Repository) PasswordlessTokenCreate(ctx context.Context, token *models.PasswordlessToken) (*models.PasswordlessToken, error) {
-	query := r.QueryPasswordlessTokenInsert(ctx, token)
+	stored := *token
+	stored.Token = HashToken(token.Token)
+	stored.ExpiresAt = stored.ExpiresAt.UTC()
+	stored.CreatedAt = stored.CreatedAt.UTC()
+	query := r.QueryPasswordlessTokenInsert(ctx, &stored)
 	createdToken, err := bob.One(ctx, r.bdb, query, scan.StructMapper[*models.PasswordlessToken]())
 	if err != nil {
 		xlog.Error("Failed to create passwordless token", "error", err, "email", token.Email)
@@ -176,10 +324,10 @@ func (r Repository) PasswordlessTokenCreate(ctx context.Context, token *models.P
 
 // PasswordlessTokenGetByToken retrieves a passwordless token by its token value.
 func (r Repository) PasswordlessTokenGetByToken(ctx context.Context, tokenValue string) (*models.PasswordlessToken, error) {
-	query := r.QueryPasswordlessTokenGetByToken(ctx, tokenValue)
+	query := r.QueryPasswordlessTokenGetByToken(ctx, HashToken(tokenValue))
 	token, err := bob.One(ctx, r.bdb, query, scan.StructMapper[*models.PasswordlessToken]())
 	if err != nil {
-		xlog.Error("Failed to get passwordless token by token", "error", err, "token", tokenValue)
+		xlog.Error("Failed to get passwordless token by token", "error", err)
 		return nil, err
 	}
 	return token, nil
@@ -187,20 +335,26 @@ func (r Repository) PasswordlessTokenGetByToken(ctx context.Context, tokenValue
 
 // PasswordlessTokenDelete deletes a passwordless token from the database.
 func (r Repository) PasswordlessTokenDelete(ctx context.Context, tokenValue string) error {
-	query := r.QueryPasswordlessTokenDelete(ctx, tokenValue)
+	query := r.QueryPasswordlessTokenDelete(ctx, HashToken(tokenValue))
 	if _, err := bob.Exec(ctx, r.bdb, query); err != nil {
-		xlog.Error("Failed to delete passwordless token", "error", err, "token", tokenValue)
+		xlog.Error("Failed to delete passwordless token", "error", err)
 		return err
 	}
 	return nil
 }
 
 // TokenCreate creates a new refresh token or password reset token in the database.
+// Only the digest of the token value is stored; the returned token holds the digest.
+// Times are stored in UTC, which the expiry queries compare against.
 func (r Repository) TokenCreate(ctx context.Context, token *models.Token) (*models.Token, error) {
-	query := r.QueryTokenInsert(ctx, token)
+	stored := *token
+	stored.Token = HashToken(token.Token)
+	stored.ExpiresAt = stored.ExpiresAt.UTC()
+	stored.CreatedAt = stored.CreatedAt.UTC()
+	query := r.QueryTokenInsert(ctx, &stored)
 	createdToken, err := bob.One(ctx, r.bdb, query, scan.StructMapper[*models.Token]())
 	if err != nil {
-		xlog.Error("Failed to create token", "error", err, "token", token.Token)
+		xlog.Error("Failed to create token", "error", err, "user_id", token.UserID, "token_type", token.TokenType)
 		return nil, err
 	}
 	return createdToken, nil
@@ -219,23 +373,71 @@ func (r Repository) TokenGetByID(ctx context.Context, id string) (*models.Token,
 
 // TokenGetByToken retrieves a token by its token value.
 func (r Repository) TokenGetByToken(ctx context.Context, tokenValue string) (*models.Token, error) {
-	query := r.QueryTokenGetByToken(ctx, tokenValue)
+	query := r.QueryTokenGetByToken(ctx, HashToken(tokenValue))
 	token, err := bob.One(ctx, r.bdb, query, scan.StructMapper[*models.Token]())
 	if err != nil {
-		xlog.Error("Failed to get token by token", "error", err, "token", tokenValue)
+		xlog.Error("Failed to get token by token", "error", err)
 		return nil, err
 	}
 	return token, nil
 }
 
 // TokenRevoke marks a token as revoked in the database.
-func (r Repository) TokenRevoke(ctx context.Context, id string) error {
+// It returns 1 if the token was still active and 0 otherwise, so only one of concurrent calls gets 1.
+func (r Repository) TokenRevoke(ctx context.Context, id string) (int64, error) {
 	query := r.QueryTokenRevoke(ctx, id)
-	if _, err := bob.Exec(ctx, r.bdb, query); err != nil {
+	res, err := bob.Exec(ctx, r.bdb, query)
+	if err != nil {
 		xlog.Error("Failed to revoke token", "error", err, "id", id)
-		return err
+		return 0, err
 	}
-	return nil
+	return res.RowsAffected()
+}
+
+// TokenRevokeFamily revokes every active token of a refresh token family.
+// It returns the number of tokens that were still active.
+func (r Repository) TokenRevokeFamily(ctx context.Context, familyID string) (int64, error) {
+	query := r.QueryTokenRevokeFamily(ctx, familyID)
+	res, err := bob.Exec(ctx, r.bdb, query)
+	if err != nil {
+		xlog.Error("Failed to revoke token family", "error", err, "family_id", familyID)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// TokenRevokeByUser revokes every active token of the given type of a user.
+// It returns the number of tokens that were revoked.
+func (r Repository) TokenRevokeByUser(ctx context.Context, userID, tokenType string) (int64, error) {
+	query := r.QueryTokenRevokeByUser(ctx, userID, tokenType)
+	res, err := bob.Exec(ctx, r.bdb, query)
+	if err != nil {
+		xlog.Error("Failed to revoke user tokens", "error", err, "user_id", userID, "token_type", tokenType)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// TokenListActive retrieves the tokens of the given type of a user that are neither revoked nor expired.
+func (r Repository) TokenListActive(ctx context.Context, userID, tokenType string) ([]*models.Token, error) {
+	query := r.QueryTokenListActive(ctx, userID, tokenType)
+	tokens, err := bob.All(ctx, r.bdb, query, scan.StructMapper[*models.Token]())
+	if err != nil {
+		xlog.Error("Failed to list active tokens", "error", err, "user_id", userID, "token_type", tokenType)
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// TokenListRevoked retrieves the revoked tokens of the given type that have not expired yet.
+func (r Repository) TokenListRevoked(ctx context.Context, tokenType string) ([]*models.Token, error) {
+	query := r.QueryTokenListRevoked(ctx, tokenType)
+	tokens, err := bob.All(ctx, r.bdb, query, scan.StructMapper[*models.Token]())
+	if err != nil {
+		xlog.Error("Failed to list revoked tokens", "error", err, "token_type", tokenType)
+		return nil, err
+	}
+	return tokens, nil
 }
 
 // TokenDelete deletes a token from the database.
@@ -248,6 +450,139 @@ func (r Repository) TokenDelete(ctx context.Context, id string) error {
 	return nil
 }
 
+// RoleCreate creates a new role in the database.
+func (r Repository) RoleCreate(ctx context.Context, role *models.Role) (*models.Role, error) {
+	query := r.QueryRoleInsert(ctx, role)
+	createdRole, err := bob.One(ctx, r.bdb, query, scan.StructMapper[*models.Role]())
+	if err != nil {
+		xlog.Error("Failed to create role", "error", err, "name", role.Name)
+		return nil, err
+	}
+	return createdRole, nil
+}
+
+// RoleGetByName retrieves a role by its name.
+func (r Repository) RoleGetByName(ctx context.Context, name string) (*models.Role, error) {
+	query := r.QueryRoleGetByName(ctx, name)
+	role, err := bob.One(ctx, r.bdb, query, scan.StructMapper[*models.Role]())
+	if err != nil {
+		xlog.Error("Failed to get role by name", "error", err, "name", name)
+		return nil, err
+	}
+	return role, nil
+}
+
+// RoleList retrieves all the roles, ordered by name.
+func (r Repository) RoleList(ctx context.Context) ([]*models.Role, error) {
+	query := r.QueryRoleList(ctx)
+	roles, err := bob.All(ctx, r.bdb, query, scan.StructMapper[*models.Role]())
+	if err != nil {
+		xlog.Error("Failed to list roles", "error", err)
+		return nil, err
+	}
+	return roles, nil
+}
+
+// RoleDelete deletes a role and its permission grants from the database.
+func (r Repository) RoleDelete(ctx context.Context, id string) error {
+	// Grants are deleted explicitly, SQLite does not enforce the foreign keys unless told to
+	err := r.bdb.RunInTx(ctx, nil, func(ctx context.Context, exec bob.Executor) error {
+		if _, err := bob.Exec(ctx, exec, r.QueryRolePermissionDeleteByRole(ctx, id)); err != nil {
+			return err
+		}
+		_, err := bob.Exec(ctx, exec, r.QueryRoleDelete(ctx, id))
+		return err
+	})
+	if err != nil {
+		xlog.Error("Failed to delete role", "error", err, "id", id)
+		return err
+	}
+	return nil
+}
+
+// RolePermissionAdd grants a permission to a role. Granting it again is a no-op.
+func (r Repository) RolePermissionAdd(ctx context.Context, roleID, permissionID string) error {
+	query := r.QueryRolePermissionInsert(ctx, roleID, permissionID)
+	if _, err := bob.Exec(ctx, r.bdb, query); err != nil {
+		xlog.Error("Failed to grant permission", "error", err, "role_id", roleID, "permission_id", permissionID)
+		return err
+	}
+	return nil
+}
+
+// RolePermissionRemove revokes a permission from a role.
+func (r Repository) RolePermissionRemove(ctx context.Context, roleID, permissionID string) error {
+	query := r.QueryRolePermissionDelete(ctx, roleID, permissionID)
+	if _, err := bob.Exec(ctx, r.bdb, query); err != nil {
+		xlog.Error("Failed to revoke permission", "error", err, "role_id", roleID, "permission_id", permissionID)
+		return err
+	}
+	return nil
+}
+
+// PermissionCreate creates a new permission in the database.
+func (r Repository) PermissionCreate(ctx context.Context, permission *models.Permission) (*models.Permission, error) {
+	query := r.QueryPermissionInsert(ctx, permission)
+	createdPermission, err := bob.One(ctx, r.bdb, query, scan.StructMapper[*models.Permission]())
+	if err != nil {
+		xlog.Error("Failed to create permission", "error", err, "name", permission.Name)
+		return nil, err
+	}
+	return createdPermission, nil
+}
+
+// PermissionGetByName retrieves a permission by its name.
+func (r Repository) PermissionGetByName(ctx context.Context, name string) (*models.Permission, error) {
+	query := r.QueryPermissionGetByName(ctx, name)
+	permission, err := bob.One(ctx, r.bdb, query, scan.StructMapper[*models.Permission]())
+	if err != nil {
+		xlog.Error("Failed to get permission by name", "error", err, "name", name)
+		return nil, err
+	}
+	return permission, nil
+}
+
+// PermissionList retrieves all the permissions, ordered by name.
+func (r Repository) PermissionList(ctx context.Context) ([]*models.Permission, error) {
+	query := r.QueryPermissionList(ctx)
+	permissions, err := bob.All(ctx, r.bdb, query, scan.StructMapper[*models.Permission]())
+	if err != nil {
+		xlog.Error("Failed to list permissions", "error", err)
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// PermissionListByRoles retrieves the permissions granted to any of the given roles, ordered by name.
+func (r Repository) PermissionListByRoles(ctx context.Context, roles []string) ([]*models.Permission, error) {
+	if len(roles) == 0 {
+		return []*models.Permission{}, nil
+	}
+	query := r.QueryPermissionListByRoles(ctx, roles)
+	permissions, err := bob.All(ctx, r.bdb, query, scan.StructMapper[*models.Permission]())
+	if err != nil {
+		xlog.Error("Failed to list permissions by roles", "error", err, "roles", roles)
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// PermissionDelete deletes a permission and its grants from the database.
+func (r Repository) PermissionDelete(ctx context.Context, id string) error {
+	err := r.bdb.RunInTx(ctx, nil, func(ctx context.Context, exec bob.Executor) error {
+		if _, err := bob.Exec(ctx, exec, r.QueryRolePermissionDeleteByPermission(ctx, id)); err != nil {
+			return err
+		}
+		_, err := bob.Exec(ctx, exec, r.QueryPermissionDelete(ctx, id))
+		return err
+	})
+	if err != nil {
+		xlog.Error("Failed to delete permission", "error", err, "id", id)
+		return err
+	}
+	return nil
+}
+
 func getDialectQuery(dbDialect string) Querier {
 	switch dbDialect {
 	case "postgres":
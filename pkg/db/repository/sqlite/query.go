@@ -135,13 +135,70 @@ func (q *SqliteQuerier) QueryUserUpdate(ctx context.Context, user *models.User)
 		qm = append(qm, um.SetCol(models.ColumnEmailVerifiedAt).ToArg(user.EmailVerifiedAt))
 	}
 
-	if user.Roles != "" {
+	if user.Roles != nil {
 		qm = append(qm, um.SetCol(models.ColumnRoles).ToArg(user.Roles))
 	}
 
 	return sqlite.Update(qm...)
 }
 
+func (q *SqliteQuerier) QueryUserRevokeTokens(ctx context.Context, id string, before time.Time) bob.Query {
+	return sqlite.Update(
+		um.Table(models.TableUser),
+		um.SetCol(models.ColumnTokensValidAfter).ToArg(before),
+		um.Where(sqlite.Quote("id").EQ(sqlite.Arg(id))),
+	)
+}
+
+func (q *SqliteQuerier) QueryUserListTokensRevoked(ctx context.Context) bob.Query {
+	return sqlite.Select(sm.From(models.TableUser), sm.Where(sqlite.Quote(models.ColumnTokensValidAfter).IsNotNull()))
+}
+
+func (q *SqliteQuerier) QueryUserActivityUpdate(ctx context.Context, id string, at time.Time) bob.Query {
+	return sqlite.Update(
+		um.Table(models.TableUser),
+		um.SetCol(models.ColumnLastActiveAt).ToArg(at),
+		um.Where(sqlite.Quote("id").EQ(sqlite.Arg(id))),
+		um.Where(sqlite.Quote(models.ColumnLastActiveAt).IsNull().
+			Or(sqlite.Quote(models.ColumnLastActiveAt).LT(sqlite.Arg(at)))),
+	)
+}
+
+func (q *SqliteQuerier) QueryUserListInactive(ctx context.Context, before time.Time) bob.Query {
+	return sqlite.Select(
+		sm.From(models.TableUser),
+		sm.Where(sqlite.Quote(models.ColumnLastActiveAt).IsNull().
+			Or(sqlite.Quote(models.ColumnLastActiveAt).LT(sqlite.Arg(before)))),
+		sm.OrderBy(sqlite.Quote(models.ColumnLastActiveAt)).Asc(),
+	)
+}
+
+func (q *SqliteQuerier) QueryUserSoftDelete(ctx context.Context, id string, at time.Time) bob.Query {
+	return sqlite.Update(
+		um.Table(models.TableUser),
+		um.SetCol(models.ColumnDeletedAt).ToArg(at),
+		um.Where(sqlite.Quote("id").EQ(sqlite.Arg(id))),
+		um.Where(sqlite.Quote(models.ColumnDeletedAt).IsNull()),
+	)
+}
+
+func (q *SqliteQuerier) QueryUserRestore(ctx context.Context, id string) bob.Query {
+	return sqlite.Update(
+		um.Table(models.TableUser),
+		um.SetCol(models.ColumnDeletedAt).ToArg(nil),
+		um.Where(sqlite.Quote("id").EQ(sqlite.Arg(id))),
+	)
+}
+
+func (q *SqliteQuerier) QueryUserListDeleted(ctx context.Context, before time.Time) bob.Query {
+	return sqlite.Select(
+		sm.From(models.TableUser),
+		sm.Where(sqlite.Quote(models.ColumnDeletedAt).IsNotNull()),
+		sm.Where(sqlite.Quote(models.ColumnDeletedAt).LT(sqlite.Arg(before))),
+		sm.OrderBy(sqlite.Quote(models.ColumnDeletedAt)).Asc(),
+	)
+}
+
 func (q *SqliteQuerier) QueryUserDelete(ctx context.Context, id string) bob.Query {
 	return sqlite.Delete(dm.From(models.TableUser), dm.Where(sqlite.Quote("id").EQ(sqlite.Arg(id))))
 }
@@ -156,6 +213,7 @@ func (q *SqliteQuerier) QueryTokenInsert(ctx context.Context, token *models.Toke
 			models.ColumnCreatedAt,
 			models.ColumnRevoked,
 			models.ColumnMetadata,
+			models.ColumnFamilyID,
 		),
 		im.Values(
 			sqlite.Arg(token.UserID),
@@ -165,6 +223,7 @@ func (q *SqliteQuerier) QueryTokenInsert(ctx context.Context, token *models.Toke
 			sqlite.Arg(token.CreatedAt),
 			sqlite.Arg(token.Revoked),
 			sqlite.Arg(token.Metadata),
+			sqlite.Arg(token.FamilyID),
 		),
 		im.Returning("*"),
 	)
@@ -183,6 +242,45 @@ func (q *SqliteQuerier) QueryTokenRevoke(ctx context.Context, id string) bob.Que
 		um.Table(models.TableToken),
 		um.SetCol(models.ColumnRevoked).ToArg(true),
 		um.Where(sqlite.Quote("id").EQ(sqlite.Arg(id))),
+		um.Where(sqlite.Quote(models.ColumnRevoked).EQ(sqlite.Arg(false))),
+	)
+}
+
+func (q *SqliteQuerier) QueryTokenRevokeFamily(ctx context.Context, familyID string) bob.Query {
+	return sqlite.Update(
+		um.Table(models.TableToken),
+		um.SetCol(models.ColumnRevoked).ToArg(true),
+		um.Where(sqlite.Quote(models.ColumnFamilyID).EQ(sqlite.Arg(familyID))),
+		um.Where(sqlite.Quote(models.ColumnRevoked).EQ(sqlite.Arg(false))),
+	)
+}
+
+func (q *SqliteQuerier) QueryTokenRevokeByUser(ctx context.Context, userID, tokenType string) bob.Query {
+	return sqlite.Update(
+		um.Table(models.TableToken),
+		um.SetCol(models.ColumnRevoked).ToArg(true),
+		um.Where(sqlite.Quote(models.ColumnUserID).EQ(sqlite.Arg(userID))),
+		um.Where(sqlite.Quote(models.ColumnTokenType).EQ(sqlite.Arg(tokenType))),
+		um.Where(sqlite.Quote(models.ColumnRevoked).EQ(sqlite.Arg(false))),
+	)
+}
+
+func (q *SqliteQuerier) QueryTokenListActive(ctx context.Context, userID, tokenType string) bob.Query {
+	return sqlite.Select(
+		sm.From(models.TableToken),
+		sm.Where(sqlite.Quote(models.ColumnUserID).EQ(sqlite.Arg(userID))),
+		sm.Where(sqlite.Quote(models.ColumnTokenType).EQ(sqlite.Arg(tokenType))),
+		sm.Where(sqlite.Quote(models.ColumnRevoked).EQ(sqlite.Arg(false))),
+		sm.Where(sqlite.Quote(models.ColumnExpiresAt).GT(sqlite.Arg(time.Now().UTC()))),
+	)
+}
+
+func (q *SqliteQuerier) QueryTokenListRevoked(ctx context.Context, tokenType string) bob.Query {
+	return sqlite.Select(
+		sm.From(models.TableToken),
+		sm.Where(sqlite.Quote(models.ColumnTokenType).EQ(sqlite.Arg(tokenType))),
+		sm.Where(sqlite.Quote(models.ColumnRevoked).EQ(sqlite.Arg(true))),
+		sm.Where(sqlite.Quote(models.ColumnExpiresAt).GT(sqlite.Arg(time.Now().UTC()))),
 	)
 }
 
@@ -190,6 +288,10 @@ func (q *SqliteQuerier) QueryTokenDelete(ctx context.Context, id string) bob.Que
 	return sqlite.Delete(dm.From(models.TableToken), dm.Where(sqlite.Quote("id").EQ(sqlite.Arg(id))))
 }
 
+func (q *SqliteQuerier) QueryTokenDeleteByUser(ctx context.Context, userID string) bob.Query {
+	return sqlite.Delete(dm.From(models.TableToken), dm.Where(sqlite.Quote(models.ColumnUserID).EQ(sqlite.Arg(userID))))
+}
+
 func (q *SqliteQuerier) QueryPasswordlessTokenInsert(ctx context.Context, token *models.PasswordlessToken) bob.Query {
 	return sqlite.Insert(
 		im.Into(models.TablePasswordlessToken,
@@ -221,3 +323,123 @@ func (q *SqliteQuerier) QueryPasswordlessTokenDelete(ctx context.Context, token
 		dm.Where(sqlite.Quote(models.ColumnToken).EQ(sqlite.Arg(token))),
 	)
 }
+
+func (q *SqliteQuerier) QueryPasswordlessTokenDeleteByEmail(ctx context.Context, email string) bob.Query {
+	return sqlite.Delete(
+		dm.From(models.TablePasswordlessToken),
+		dm.Where(sqlite.Quote(models.ColumnEmail).EQ(sqlite.Arg(email))),
+	)
+}
+
+func (q *SqliteQuerier) QueryRoleInsert(ctx context.Context, role *models.Role) bob.Query {
+	return sqlite.Insert(
+		im.Into(models.TableRole,
+			models.ColumnName,
+			models.ColumnDescription,
+			models.ColumnCreatedAt,
+		),
+		im.Values(
+			sqlite.Arg(role.Name),
+			sqlite.Arg(role.Description),
+			sqlite.Arg(role.CreatedAt),
+		),
+		im.Returning("*"),
+	)
+}
+
+func (q *SqliteQuerier) QueryRoleGetByName(ctx context.Context, name string) bob.Query {
+	return sqlite.Select(sm.From(models.TableRole), sm.Where(sqlite.Quote(models.ColumnName).EQ(sqlite.Arg(name))))
+}
+
+func (q *SqliteQuerier) QueryRoleList(ctx context.Context) bob.Query {
+	return sqlite.Select(sm.From(models.TableRole), sm.OrderBy(models.ColumnName))
+}
+
+func (q *SqliteQuerier) QueryRoleDelete(ctx context.Context, id string) bob.Query {
+	return sqlite.Delete(dm.From(models.TableRole), dm.Where(sqlite.Quote("id").EQ(sqlite.Arg(id))))
+}
+
+func (q *SqliteQuerier) QueryPermissionInsert(ctx context.Context, permission *models.Permission) bob.Query {
+	return sqlite.Insert(
+		im.Into(models.TablePermission,
+			models.ColumnName,
+			models.ColumnDescription,
+			models.ColumnCreatedAt,
+		),
+		im.Values(
+			sqlite.Arg(permission.Name),
+			sqlite.Arg(permission.Description),
+			sqlite.Arg(permission.CreatedAt),
+		),
+		im.Returning("*"),
+	)
+}
+
+func (q *SqliteQuerier) QueryPermissionGetByName(ctx context.Context, name string) bob.Query {
+	return sqlite.Select(sm.From(models.TablePermission), sm.Where(sqlite.Quote(models.ColumnName).EQ(sqlite.Arg(name))))
+}
+
+func (q *SqliteQuerier) QueryPermissionList(ctx context.Context) bob.Query {
+	return sqlite.Select(sm.From(models.TablePermission), sm.OrderBy(models.ColumnName))
+}
+
+func (q *SqliteQuerier) QueryPermissionListByRoles(ctx context.Context, roles []string) bob.Query {
+	names := make([]any, len(roles))
+	for i, role := range roles {
+		names[i] = role
+	}
+	return sqlite.Select(
+		sm.Distinct(),
+		sm.Columns(
+			sqlite.Quote(models.TablePermission, "id"),
+			sqlite.Quote(models.TablePermission, models.ColumnName),
+			sqlite.Quote(models.TablePermission, models.ColumnDescription),
+			sqlite.Quote(models.TablePermission, models.ColumnCreatedAt),
+		),
+		sm.From(models.TablePermission),
+		sm.InnerJoin(models.TableRolePermission).OnEQ(
+			sqlite.Quote(models.TableRolePermission, models.ColumnPermissionID),
+			sqlite.Quote(models.TablePermission, "id"),
+		),
+		sm.InnerJoin(models.TableRole).OnEQ(
+			sqlite.Quote(models.TableRole, "id"),
+			sqlite.Quote(models.TableRolePermission, models.ColumnRoleID),
+		),
+		sm.Where(sqlite.Quote(models.TableRole, models.ColumnName).In(sqlite.Arg(names...))),
+		sm.OrderBy(sqlite.Quote(models.TablePermission, models.ColumnName)),
+	)
+}
+
+func (q *SqliteQuerier) QueryPermissionDelete(ctx context.Context, id string) bob.Query {
+	return sqlite.Delete(dm.From(models.TablePermission), dm.Where(sqlite.Quote("id").EQ(sqlite.Arg(id))))
+}
+
+func (q *SqliteQuerier) QueryRolePermissionInsert(ctx context.Context, roleID, permissionID string) bob.Query {
+	return sqlite.Insert(
+		im.Into(models.TableRolePermission, models.ColumnRoleID, models.ColumnPermissionID),
+		im.Values(sqlite.Arg(roleID), sqlite.Arg(permissionID)),
+		im.OnConflict(models.ColumnRoleID, models.ColumnPermissionID).DoNothing(),
+	)
+}
+
+func (q *SqliteQuerier) QueryRolePermissionDelete(ctx context.Context, roleID, permissionID string) bob.Query {
+	return sqlite.Delete(
+		dm.From(models.TableRolePermission),
+		dm.Where(sqlite.Quote(models.ColumnRoleID).EQ(sqlite.Arg(roleID))),
+		dm.Where(sqlite.Quote(models.ColumnPermissionID).EQ(sqlite.Arg(permissionID))),
+	)
+}
+
+func (q *SqliteQuerier) QueryRolePermissionDeleteByRole(ctx context.Context, roleID string) bob.Query {
+	return sqlite.Delete(
+		dm.From(models.TableRolePermission),
+		dm.Where(sqlite.Quote(models.ColumnRoleID).EQ(sqlite.Arg(roleID))),
+	)
+}
+
+func (q *SqliteQuerier) QueryRolePermissionDeleteByPermission(ctx context.Context, permissionID string) bob.Query {
+	return sqlite.Delete(
+		dm.From(models.TableRolePermission),
+		dm.Where(sqlite.Quote(models.ColumnPermissionID).EQ(sqlite.Arg(permissionID))),
+	)
+}
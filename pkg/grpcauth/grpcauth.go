@@ -0,0 +1,156 @@
+// Package grpcauth provides gRPC server interceptors authenticating requests with ezauth access tokens.
+//
+// The interceptors read the bearer token from the "authorization" metadata and verify it
+// with handler.Handler.Authenticate, like AuthMiddleware does for HTTP requests,
+// so handler.GetUserID, handler.GetClaims and handler.GetUser work in gRPC methods too.
+package grpcauth
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/josuebrunel/ezauth/pkg/handler"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator verifies an access token and returns ctx populated with its user and claims.
+// It is implemented by *handler.Handler.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (context.Context, error)
+}
+
+// AuthorizeFunc decides whether the authenticated user of ctx may call fullMethod.
+// A non-nil error is returned to the client with codes.PermissionDenied.
+type AuthorizeFunc func(ctx context.Context, fullMethod string) error
+
+// Option configures the interceptors.
+type Option func(*options)
+
+type options struct {
+	publicMethods []string
+	authorize     AuthorizeFunc
+}
+
+// WithPublicMethods lets calls to the given methods through without a token,
+// for instance "/grpc.health.v1.Health/Check".
+func WithPublicMethods(fullMethods ...string) Option {
+	return func(o *options) {
+		o.publicMethods = append(o.publicMethods, fullMethods...)
+	}
+}
+
+// WithAuthorizer checks the authenticated user of every call with fn.
+func WithAuthorizer(fn AuthorizeFunc) Option {
+	return func(o *options) {
+		o.authorize = fn
+	}
+}
+
+// RequireAnyRole returns an AuthorizeFunc allowing only users having at least one of roles.
+func RequireAnyRole(roles ...string) AuthorizeFunc {
+	return func(ctx context.Context, fullMethod string) error {
+		claims, err := handler.GetClaims(ctx)
+		if err != nil {
+			return err
+		}
+		if !claims.Roles.HasAny(roles...) {
+			return handler.ErrInsufficientRole
+		}
+		return nil
+	}
+}
+
+// RequirePermission returns an AuthorizeFunc allowing only users whose roles grant permission.
+func RequirePermission(permission string) AuthorizeFunc {
+	return func(ctx context.Context, fullMethod string) error {
+		claims, err := handler.GetClaims(ctx)
+		if err != nil {
+			return err
+		}
+		if !claims.HasPermission(permission) {
+			return handler.ErrInsufficientPermission
+		}
+		return nil
+	}
+}
+
+// UnaryServerInterceptor returns a unary interceptor authenticating calls with auth.
+func UnaryServerInterceptor(auth Authenticator, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		ctx, err := o.authenticate(ctx, auth, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a stream interceptor authenticating calls with auth.
+func StreamServerInterceptor(auth Authenticator, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+		ctx, err := o.authenticate(ss.Context(), auth, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return next(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *options) authenticate(ctx context.Context, auth Authenticator, fullMethod string) (context.Context, error) {
+	if slices.Contains(o.publicMethods, fullMethod) {
+		return ctx, nil
+	}
+
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	ctx, err = auth.Authenticate(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if o.authorize != nil {
+		if err := o.authorize(ctx, fullMethod); err != nil {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	return ctx, nil
+}
+
+// bearerToken reads the token of the "authorization: Bearer <token>" metadata.
+func bearerToken(ctx context.Context) (string, error) {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return "", handler.ErrAuthorizationHeaderRequired
+	}
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", handler.ErrBearerTokenRequired
+	}
+	return token, nil
+}
+
+// serverStream overrides the context of a stream with the authenticated one.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,191 @@
+package grpcauth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/config"
+	"github.com/josuebrunel/ezauth/pkg/db/migrations"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/ezauth/pkg/handler"
+	"github.com/josuebrunel/ezauth/pkg/service"
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// healthServer records the user ID found in the context of the calls it serves.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	userID string
+}
+
+func (s *healthServer) Check(ctx context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	s.userID, _ = handler.GetUserID(ctx)
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *healthServer) Watch(_ *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	s.userID, _ = handler.GetUserID(stream.Context())
+	return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+}
+
+func setupTestHandler(t *testing.T) (*handler.Handler, *service.Auth) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%d?mode=memory&cache=shared", time.Now().UnixNano())
+	cfg := &config.Config{
+		DB: config.Database{
+			Dialect: "sqlite3",
+			DSN:     dsn,
+		},
+		JWTSecret: "test-secret",
+		Addr:      ":8080",
+	}
+	authSvc, err := service.NewFromConfig(cfg, "auth")
+	if err != nil {
+		t.Fatalf("failed to create auth service: %v", err)
+	}
+	if err := migrations.MigrateUpWithDBConn(authSvc.Repo.DB(), "sqlite3"); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+	return handler.New(authSvc, "auth"), authSvc
+}
+
+// setupTestServer serves a health server behind the interceptors and returns a client for it.
+func setupTestServer(t *testing.T, h *handler.Handler, opts ...Option) (grpc_health_v1.HealthClient, *healthServer) {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(h, opts...)),
+		grpc.StreamInterceptor(StreamServerInterceptor(h, opts...)),
+	)
+	health := &healthServer{}
+	grpc_health_v1.RegisterHealthServer(srv, health)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return grpc_health_v1.NewHealthClient(conn), health
+}
+
+func createTestUser(t *testing.T, svc *service.Auth, email, roles string) (*models.User, string) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := svc.Repo.UserCreate(ctx, &models.User{Email: email, Provider: "local", Roles: models.ParseRoles(roles)})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+	tokens, err := svc.TokenCreate(ctx, user)
+	if err != nil {
+		t.Fatalf("failed to create tokens: %v", err)
+	}
+	return user, tokens.AccessToken
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	h, svc := setupTestHandler(t)
+	client, health := setupTestServer(t, h)
+	user, token := createTestUser(t, svc, "grpc-unary@example.com", "")
+
+	t.Run("ValidToken", func(t *testing.T) {
+		if _, err := client.Check(withToken(token), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+			t.Fatalf("expected call to succeed, got %v", err)
+		}
+		if health.userID != user.ID {
+			t.Errorf("expected user ID %q in context, got %q", user.ID, health.userID)
+		}
+	})
+
+	t.Run("MissingToken", func(t *testing.T) {
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("expected Unauthenticated, got %v", err)
+		}
+	})
+
+	t.Run("WrongScheme", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic "+token)
+		_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("expected Unauthenticated, got %v", err)
+		}
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		_, err := client.Check(withToken("invalid-token"), &grpc_health_v1.HealthCheckRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("expected Unauthenticated, got %v", err)
+		}
+	})
+
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	h, svc := setupTestHandler(t)
+	client, health := setupTestServer(t, h)
+	user, token := createTestUser(t, svc, "grpc-stream@example.com", "")
+
+	stream, err := client.Watch(withToken(token), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("expected stream to succeed, got %v", err)
+	}
+	if health.userID != user.ID {
+		t.Errorf("expected user ID %q in context, got %q", user.ID, health.userID)
+	}
+
+	stream, err = client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestPublicMethods(t *testing.T) {
+	h, _ := setupTestHandler(t)
+	client, _ := setupTestServer(t, h, WithPublicMethods(grpc_health_v1.Health_Check_FullMethodName))
+
+	if _, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Errorf("expected public method to succeed without token, got %v", err)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	h, svc := setupTestHandler(t)
+	client, _ := setupTestServer(t, h, WithAuthorizer(RequireAnyRole("admin")))
+	_, adminToken := createTestUser(t, svc, "grpc-admin@example.com", "admin")
+	_, userToken := createTestUser(t, svc, "grpc-user@example.com", "user")
+
+	if _, err := client.Check(withToken(adminToken), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Errorf("expected admin call to succeed, got %v", err)
+	}
+	_, err := client.Check(withToken(userToken), &grpc_health_v1.HealthCheckRequest{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+)
+
+// UserListInactive lists the users who have not been active since a given time.
+// @Summary List inactive users
+// @Description List the users not active since the given time, including those never active, least recently active first. Requires the role set in EZAUTH_ACTIVITY_ADMIN_ROLE; the route is left out when it is unset
+// @Tags admin
+// @Produce json
+// @Param since query string true "RFC 3339 time, e.g. 2026-01-01T00:00:00Z"
+// @Security BearerAuth
+// @Success 200 {object} ApiResponse[[]models.User]
+// @Failure 400 {object} ApiResponse[string]
+// @Failure 403 {object} ApiResponse[string]
+// @Failure 500 {object} ApiResponse[string]
+// @Router /auth/users/inactive [get]
+func (h *Handler) UserListInactive(w http.ResponseWriter, r *http.Request) {
+	since, err := time.Parse(time.RFC3339, r.URL.Query().Get("since"))
+	if err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrInvalidSince)
+		return
+	}
+
+	users, err := h.svc.UserListInactive(r.Context(), since)
+	if err != nil {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotListUsers)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, users, nil)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/ezauth/pkg/service"
+)
+
+// userMemo loads the authenticated user at most once per request.
+type userMemo struct {
+	once sync.Once
+	load func(ctx context.Context) (*models.User, error)
+	user *models.User
+	err  error
+}
+
+func (m *userMemo) get(ctx context.Context) (*models.User, error) {
+	m.once.Do(func() {
+		m.user, m.err = m.load(ctx)
+	})
+	return m.user, m.err
+}
+
+// GetClaims retrieves the verified access token claims from the request context.
+// It returns ErrClaimsNotFoundInContext if the request did not go through AuthMiddleware.
+func GetClaims(ctx context.Context) (*service.Claims, error) {
+	claims, ok := ctx.Value(claimsContextKey).(jwt.MapClaims)
+	if !ok {
+		return nil, ErrClaimsNotFoundInContext
+	}
+	return service.NewClaims(claims), nil
+}
+
+// GetUser retrieves the authenticated user from the database.
+// The user is loaded on the first call and shared by the following calls during the request.
+// It returns ErrUserIDNotFoundInContext if the request did not go through AuthMiddleware.
+func GetUser(ctx context.Context) (*models.User, error) {
+	memo, ok := ctx.Value(userMemoContextKey).(*userMemo)
+	if !ok {
+		return nil, ErrUserIDNotFoundInContext
+	}
+	return memo.get(ctx)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/service"
+	"github.com/josuebrunel/ezauth/pkg/util"
+)
+
+// Cookie and header names used in cookie mode.
+// The CSRF cookie is readable by scripts, which echo its value in the CSRF header.
+const (
+	AccessTokenCookie  = "ezauth_access_token"
+	RefreshTokenCookie = "ezauth_refresh_token"
+	CSRFCookie         = "ezauth_csrf_token"
+	CSRFHeader         = "X-CSRF-Token"
+)
+
+// cookieMode reports whether tokens are exchanged through cookies.
+func (h *Handler) cookieMode() bool {
+	return h.svc.Cfg.Cookie.Enabled
+}
+
+// writeTokenResponse writes tokens issued for a login, registration or refresh.
+// In cookie mode they are set in cookies along with a new CSRF token, and left out of the body.
+func (h *Handler) writeTokenResponse(w http.ResponseWriter, status int, tokenResp *service.TokenResponse) {
+	if h.cookieMode() {
+		h.setTokenCookies(w, tokenResp)
+		tokenResp = &service.TokenResponse{ExpiresIn: tokenResp.ExpiresIn, TokenType: tokenResp.TokenType}
+	}
+	WriteJSONResponse(w, status, tokenResp, nil)
+}
+
+func (h *Handler) setTokenCookies(w http.ResponseWriter, tokenResp *service.TokenResponse) {
+	refreshTTL := h.svc.Cfg.Token.RefreshTTL
+	if refreshTTL <= 0 {
+		refreshTTL = service.DefaultRefreshTokenTTL
+	}
+	http.SetCookie(w, h.cookie(AccessTokenCookie, tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn)*time.Second, true))
+	http.SetCookie(w, h.cookie(RefreshTokenCookie, tokenResp.RefreshToken, refreshTTL, true))
+	http.SetCookie(w, h.cookie(CSRFCookie, util.RandomString(32), refreshTTL, false))
+}
+
+// clearTokenCookies removes the cookies set by setTokenCookies.
+func (h *Handler) clearTokenCookies(w http.ResponseWriter) {
+	if !h.cookieMode() {
+		return
+	}
+	for _, name := range []string{AccessTokenCookie, RefreshTokenCookie, CSRFCookie} {
+		http.SetCookie(w, h.cookie(name, "", -1, true))
+	}
+}
+
+func (h *Handler) cookie(name, value string, maxAge time.Duration, httpOnly bool) *http.Cookie {
+	cfg := h.svc.Cfg.Cookie
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		MaxAge:   int(maxAge / time.Second),
+		Secure:   !cfg.Insecure,
+		HttpOnly: httpOnly,
+		SameSite: sameSite(cfg.SameSite),
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if maxAge < 0 {
+		c.MaxAge = -1
+	}
+	return c
+}
+
+func sameSite(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// cookieValue returns the value of a cookie of the request, or an empty string.
+func cookieValue(r *http.Request, name string) string {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// validCSRF checks the double-submit CSRF token of a request authenticated with cookies:
+// the CSRF header must match the CSRF cookie. Safe methods are not checked.
+func validCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	token := cookieValue(r, CSRFCookie)
+	header := r.Header.Get(CSRFHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(header)) == 1
+}
@@ -15,9 +15,34 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/auth/login": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "Public keys for verifying access tokens signed with RS256, ES256 or EdDSA. Empty for HS256.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "JSON Web Key Set",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.JWKSet"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/email/change": {
             "post": {
-                "description": "Login with email and password",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Send a confirmation link to the new email address and a revert link to the current one. Requires the current password unless the session logged in recently",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,17 +50,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "user"
                 ],
-                "summary": "Login user",
+                "summary": "Change email",
                 "parameters": [
                     {
-                        "description": "Login Request",
+                        "description": "Email Change Request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/service.RequestBasicAuth"
+                            "$ref": "#/definitions/service.RequestEmailChange"
                         }
                     }
                 ],
@@ -43,7 +68,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
                         }
                     },
                     "400": {
@@ -52,8 +77,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
@@ -67,14 +92,42 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/logout": {
-            "post": {
-                "security": [
+        "/auth/email/change/confirm": {
+            "get": {
+                "description": "Landing page of the confirmation link. The email is only changed by posting the token to the same path",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Email change confirmation link",
+                "parameters": [
                     {
-                        "BearerAuth": []
+                        "type": "string",
+                        "description": "Email Change Token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
                     }
                 ],
-                "description": "Revoke the user's refresh token",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Change the email of the user to the new address and revoke their other sessions",
                 "consumes": [
                     "application/json"
                 ],
@@ -84,15 +137,15 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Logout user",
+                "summary": "Confirm email change",
                 "parameters": [
                     {
-                        "description": "Logout Request",
+                        "description": "Email Change Confirmation",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handler.LogoutRequest"
+                            "$ref": "#/definitions/service.RequestEmailChangeToken"
                         }
                     }
                 ],
@@ -109,8 +162,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
@@ -118,32 +171,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/oauth2/{provider}/callback": {
+        "/auth/email/change/revert": {
             "get": {
-                "description": "Handle the callback from the OAuth2 provider",
+                "description": "Landing page of the revert link. The change is only reverted by posting the token to the same path",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "oauth2"
+                    "auth"
                 ],
-                "summary": "OAuth2 Callback",
+                "summary": "Email change revert link",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "OAuth2 Provider (google, github, facebook)",
-                        "name": "provider",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Authorization Code",
-                        "name": "code",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "CSRF State",
-                        "name": "state",
+                        "description": "Email Revert Token",
+                        "name": "token",
                         "in": "query",
                         "required": true
                     }
@@ -152,59 +194,65 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
                         }
                     },
-                    "302": {
-                        "description": "Found"
-                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/handler.ApiResponse-string"
-                        }
                     }
                 }
-            }
-        },
-        "/auth/oauth2/{provider}/login": {
-            "get": {
-                "description": "Redirect to the OAuth2 provider login page",
+            },
+            "post": {
+                "description": "Cancel or roll back an email change and log the user out of every device",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "oauth2"
+                    "auth"
                 ],
-                "summary": "OAuth2 Login",
+                "summary": "Revert email change",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "OAuth2 Provider (google, github, facebook)",
-                        "name": "provider",
-                        "in": "path",
-                        "required": true
+                        "description": "Email Change Revert",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.RequestEmailChangeToken"
+                        }
                     }
                 ],
                 "responses": {
-                    "307": {
-                        "description": "Temporary Redirect"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
                     }
                 }
             }
         },
-        "/auth/password-reset/confirm": {
+        "/auth/email/verify": {
             "post": {
-                "description": "Reset password using the token sent via email",
+                "description": "Verify the email address of a user with the token sent via email",
                 "consumes": [
                     "application/json"
                 ],
@@ -214,15 +262,15 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Confirm password reset",
+                "summary": "Verify email",
                 "parameters": [
                     {
-                        "description": "Password Reset Confirmation",
+                        "description": "Email Verification",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/service.RequestPasswordResetConfirm"
+                            "$ref": "#/definitions/service.RequestEmailVerificationConfirm"
                         }
                     }
                 ],
@@ -242,9 +290,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/password-reset/request": {
+        "/auth/email/verify/resend": {
             "post": {
-                "description": "Send a password reset link to the user's email",
+                "description": "Send a new verification token to the user's email, if the account exists and is not verified yet",
                 "consumes": [
                     "application/json"
                 ],
@@ -254,15 +302,15 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Request password reset",
+                "summary": "Resend verification email",
                 "parameters": [
                     {
-                        "description": "Password Reset Request",
+                        "description": "Verification Email Request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/service.RequestPasswordReset"
+                            "$ref": "#/definitions/service.RequestEmailVerification"
                         }
                     }
                 ],
@@ -288,50 +336,64 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/passwordless/login": {
-            "get": {
-                "description": "Authenticate using the token from the magic link",
+        "/auth/introspect": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "RFC 7662 token introspection for access and refresh tokens. Requires client credentials (HTTP Basic or client_id/client_secret form fields) or, when an admin role is configured, the bearer token of a user with that role. The response is not wrapped in the ApiResponse envelope.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "token"
                 ],
-                "summary": "Magic link login",
+                "summary": "Token introspection",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Magic Link Token",
+                        "description": "Token to introspect",
                         "name": "token",
-                        "in": "query",
+                        "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "access_token or refresh_token",
+                        "name": "token_type_hint",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
+                            "$ref": "#/definitions/service.IntrospectionResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/handler.ApiResponse-string"
+                            "$ref": "#/definitions/handler.OAuthErrorResponse"
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/handler.ApiResponse-string"
+                            "$ref": "#/definitions/handler.OAuthErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/auth/passwordless/request": {
+        "/auth/login": {
             "post": {
-                "description": "Send a magic login link to the user's email",
+                "description": "Login with email and password. When email verification is required, unverified users get 403 \"email not verified\".",
                 "consumes": [
                     "application/json"
                 ],
@@ -341,15 +403,15 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Request magic link",
+                "summary": "Login user",
                 "parameters": [
                     {
-                        "description": "Passwordless Request",
+                        "description": "Login Request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/service.RequestPasswordless"
+                            "$ref": "#/definitions/service.RequestBasicAuth"
                         }
                     }
                 ],
@@ -357,7 +419,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
                         }
                     },
                     "400": {
@@ -366,48 +428,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
-                    }
-                }
-            }
-        },
-        "/auth/register": {
-            "post": {
-                "description": "Register a new user with basic authentication",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "auth"
-                ],
-                "summary": "Register a new user",
-                "parameters": [
-                    {
-                        "description": "Registration Request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/service.RequestBasicAuth"
-                        }
-                    }
-                ],
-                "responses": {
-                    "201": {
-                        "description": "Created",
-                        "schema": {
-                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
-                        }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
@@ -421,9 +449,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/token/refresh": {
+        "/auth/logout": {
             "post": {
-                "description": "Get a new access token using a refresh token",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke the user's refresh token and the access token used for the request. In cookie mode, the refresh token cookie is used instead of the body.",
                 "consumes": [
                     "application/json"
                 ],
@@ -433,15 +466,15 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Refresh access token",
+                "summary": "Logout user",
                 "parameters": [
                     {
-                        "description": "Refresh Token Request",
+                        "description": "Logout Request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handler.RefreshTokenRequest"
+                            "$ref": "#/definitions/handler.LogoutRequest"
                         }
                     }
                 ],
@@ -449,7 +482,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
                         }
                     },
                     "400": {
@@ -458,8 +491,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/handler.ApiResponse-string"
                         }
@@ -467,21 +500,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/user": {
-            "delete": {
+        "/auth/logout/all": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Delete the authenticated user's account",
+                "description": "Revoke all refresh tokens of the user and every access token issued so far",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "auth"
                 ],
-                "summary": "Delete user",
+                "summary": "Logout from all devices",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -498,15 +531,677 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/userinfo": {
+        "/auth/oauth2/{provider}/callback": {
             "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
+                "description": "Handle the callback from the OAuth2 provider",
+                "tags": [
+                    "oauth2"
                 ],
-                "description": "Retrieve information about the authenticated user",
-                "produces": [
+                "summary": "OAuth2 Callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "OAuth2 Provider (google, github, facebook)",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization Code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "CSRF State",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
+                        }
+                    },
+                    "302": {
+                        "description": "Found"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/oauth2/{provider}/login": {
+            "get": {
+                "description": "Redirect to the OAuth2 provider login page",
+                "tags": [
+                    "oauth2"
+                ],
+                "summary": "OAuth2 Login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "OAuth2 Provider (google, github, facebook)",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "307": {
+                        "description": "Temporary Redirect"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/password-reset/confirm": {
+            "post": {
+                "description": "Reset password using the token sent via email",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Confirm password reset",
+                "parameters": [
+                    {
+                        "description": "Password Reset Confirmation",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.RequestPasswordResetConfirm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/password-reset/request": {
+            "post": {
+                "description": "Send a password reset link to the user's email",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Request password reset",
+                "parameters": [
+                    {
+                        "description": "Password Reset Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.RequestPasswordReset"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/password/change": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change the password of the authenticated user given their current one, optionally logging out every other device. A notice is sent via email",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Change password",
+                "parameters": [
+                    {
+                        "description": "Password Change Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.RequestPasswordChange"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/passwordless/login": {
+            "get": {
+                "description": "Authenticate using the token from the magic link",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Magic link login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Magic Link Token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/passwordless/request": {
+            "post": {
+                "description": "Send a magic login link to the user's email",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Request magic link",
+                "parameters": [
+                    {
+                        "description": "Passwordless Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.RequestPasswordless"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/register": {
+            "post": {
+                "description": "Register a new user with basic authentication. A verification token is emailed to the user. When email verification is required, no tokens are issued until the email is verified.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Register a new user",
+                "parameters": [
+                    {
+                        "description": "Registration Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.RequestBasicAuth"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/revoke": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "RFC 7009 token revocation for access and refresh tokens. Requires client credentials (HTTP Basic or client_id/client_secret form fields) or, when an admin role is configured, the bearer token of a user with that role. Unknown tokens are ignored.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "token"
+                ],
+                "summary": "Token revocation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token to revoke",
+                        "name": "token",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "access_token or refresh_token",
+                        "name": "token_type_hint",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.OAuthErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.OAuthErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the devices the authenticated user is logged in on. The session of the access token is flagged as current.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "List sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-array_service_Session"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/sessions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Log the authenticated user out of a device by revoking the refresh tokens of the session",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Revoke session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/token/refresh": {
+            "post": {
+                "description": "Get a new access token using a refresh token. In cookie mode, the refresh token cookie is used instead of the body and the X-CSRF-Token header is required.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Refresh access token",
+                "parameters": [
+                    {
+                        "description": "Refresh Token Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.RefreshTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-service_TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/user": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Schedule the deletion of the authenticated user's account and log them out of every device. The account can be restored with the link emailed to the user until the grace period is over, then it is purged.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Delete user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update the name, locale, timezone and user metadata of the authenticated user. Email, roles, provider and app metadata cannot be changed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Update user",
+                "parameters": [
+                    {
+                        "description": "User Update Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.RequestUserUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-models_User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/user/restore": {
+            "get": {
+                "description": "Cancel the deletion of an account within the grace period. The user has to log in again.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Restore user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account Restore Token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-map_string_string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/userinfo": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve information about the authenticated user",
+                "produces": [
                     "application/json"
                 ],
                 "tags": [
@@ -529,6 +1224,58 @@ const docTemplate = `{
                 }
             }
         },
+        "/auth/users/inactive": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the users not active since the given time, including those never active, least recently active first. Requires the role set in EZAUTH_ACTIVITY_ADMIN_ROLE; the route is left out when it is unset",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List inactive users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, e.g. 2026-01-01T00:00:00Z",
+                        "name": "since",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-array_models_User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ApiResponse-string"
+                        }
+                    }
+                }
+            }
+        },
         "/ping": {
             "get": {
                 "description": "Checks if the server is running",
@@ -548,6 +1295,30 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "handler.ApiResponse-array_models_User": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.User"
+                    }
+                },
+                "error": {}
+            }
+        },
+        "handler.ApiResponse-array_service_Session": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.Session"
+                    }
+                },
+                "error": {}
+            }
+        },
         "handler.ApiResponse-map_string_string": {
             "type": "object",
             "properties": {
@@ -592,6 +1363,17 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.OAuthErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "error_description": {
+                    "type": "string"
+                }
+            }
+        },
         "handler.RefreshTokenRequest": {
             "type": "object",
             "properties": {
@@ -619,6 +1401,10 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "deleted_at": {
+                    "description": "pending deletion, purged after the grace period",
+                    "type": "string"
+                },
                 "email": {
                     "type": "string"
                 },
@@ -650,7 +1436,10 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "roles": {
-                    "type": "string"
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
                 "timezone": {
                     "type": "string"
@@ -663,6 +1452,87 @@ const docTemplate = `{
                 }
             }
         },
+        "service.IntrospectionResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "aud": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "exp": {
+                    "type": "integer"
+                },
+                "iat": {
+                    "type": "integer"
+                },
+                "iss": {
+                    "type": "string"
+                },
+                "jti": {
+                    "type": "string"
+                },
+                "scope": {
+                    "type": "string"
+                },
+                "sub": {
+                    "type": "string"
+                },
+                "token_type": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                },
+                "y": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.JWKSet": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.JWK"
+                    }
+                }
+            }
+        },
         "service.RequestBasicAuth": {
             "type": "object",
             "properties": {
@@ -685,11 +1555,57 @@ const docTemplate = `{
                 "password": {
                     "type": "string"
                 },
-                "roles": {
+                "timezone": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.RequestEmailChange": {
+            "type": "object",
+            "properties": {
+                "email": {
                     "type": "string"
                 },
-                "timezone": {
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.RequestEmailChangeToken": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.RequestEmailVerification": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.RequestEmailVerificationConfirm": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.RequestPasswordChange": {
+            "type": "object",
+            "properties": {
+                "current_password": {
+                    "type": "string"
+                },
+                "password": {
                     "type": "string"
+                },
+                "revoke_other_sessions": {
+                    "type": "boolean"
                 }
             }
         },
@@ -720,6 +1636,53 @@ const docTemplate = `{
                 }
             }
         },
+        "service.RequestUserUpdate": {
+            "type": "object",
+            "properties": {
+                "first_name": {
+                    "type": "string"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "locale": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "type": "string"
+                },
+                "user_metadata": {
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "service.Session": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "current": {
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
         "service.TokenResponse": {
             "type": "object",
             "properties": {
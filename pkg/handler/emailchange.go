@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/josuebrunel/ezauth/pkg/service"
+)
+
+// EmailChange starts changing the email of the authenticated user.
+// @Summary Change email
+// @Description Send a confirmation link to the new email address and a revert link to the current one. Requires the current password unless the session logged in recently
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body service.RequestEmailChange true "Email Change Request"
+// @Security BearerAuth
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 400 {object} ApiResponse[string]
+// @Failure 403 {object} ApiResponse[string]
+// @Failure 500 {object} ApiResponse[string]
+// @Router /auth/email/change [post]
+func (h *Handler) EmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrUserNotFoundInContext)
+		return
+	}
+
+	var req service.RequestEmailChange
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.svc.EmailChangeRequest(r.Context(), userID, currentSessionID(r), req); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidEmail), errors.Is(err, service.ErrEmailUnchanged):
+			WriteJSONResponseError(w, http.StatusBadRequest, err)
+		case errors.Is(err, service.ErrInvalidPassword), errors.Is(err, service.ErrReauthenticationRequired):
+			WriteJSONResponseError(w, http.StatusForbidden, err)
+		default:
+			WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotProcessEmailChange)
+		}
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "confirmation email sent"}, nil)
+}
+
+// EmailChangeConfirmLink handles the confirmation link sent to the new email address.
+// It changes nothing, since links may be opened by mail scanners; the change is confirmed with EmailChangeConfirm.
+// @Summary Email change confirmation link
+// @Description Landing page of the confirmation link. The email is only changed by posting the token to the same path
+// @Tags auth
+// @Produce json
+// @Param token query string true "Email Change Token"
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 400 {object} ApiResponse[string]
+// @Router /auth/email/change/confirm [get]
+func (h *Handler) EmailChangeConfirmLink(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("token") == "" {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrTokenRequired)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "post the token to confirm the email change"}, nil)
+}
+
+// EmailChangeConfirm handles the confirmation of an email change.
+// @Summary Confirm email change
+// @Description Change the email of the user to the new address and revoke their other sessions
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body service.RequestEmailChangeToken true "Email Change Confirmation"
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 400 {object} ApiResponse[string]
+// @Failure 409 {object} ApiResponse[string]
+// @Router /auth/email/change/confirm [post]
+func (h *Handler) EmailChangeConfirm(w http.ResponseWriter, r *http.Request) {
+	var req service.RequestEmailChangeToken
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrInvalidRequestBody)
+		return
+	}
+
+	if req.Token == "" {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrTokenRequired)
+		return
+	}
+
+	if _, err := h.svc.EmailChangeConfirm(r.Context(), req.Token); err != nil {
+		if errors.Is(err, service.ErrEmailTaken) {
+			WriteJSONResponseError(w, http.StatusConflict, ErrEmailTaken)
+			return
+		}
+		WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "email changed successfully"}, nil)
+}
+
+// EmailChangeRevertLink handles the revert link sent to the previous email address.
+// Like EmailChangeConfirmLink, it changes nothing; the change is reverted with EmailChangeRevert.
+// @Summary Email change revert link
+// @Description Landing page of the revert link. The change is only reverted by posting the token to the same path
+// @Tags auth
+// @Produce json
+// @Param token query string true "Email Revert Token"
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 400 {object} ApiResponse[string]
+// @Router /auth/email/change/revert [get]
+func (h *Handler) EmailChangeRevertLink(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("token") == "" {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrTokenRequired)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "post the token to revert the email change"}, nil)
+}
+
+// EmailChangeRevert handles the revert of an email change.
+// @Summary Revert email change
+// @Description Cancel or roll back an email change and log the user out of every device
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body service.RequestEmailChangeToken true "Email Change Revert"
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 400 {object} ApiResponse[string]
+// @Failure 409 {object} ApiResponse[string]
+// @Router /auth/email/change/revert [post]
+func (h *Handler) EmailChangeRevert(w http.ResponseWriter, r *http.Request) {
+	var req service.RequestEmailChangeToken
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrInvalidRequestBody)
+		return
+	}
+
+	if req.Token == "" {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrTokenRequired)
+		return
+	}
+
+	if _, err := h.svc.EmailChangeRevert(r.Context(), req.Token); err != nil {
+		if errors.Is(err, service.ErrEmailTaken) {
+			WriteJSONResponseError(w, http.StatusConflict, ErrEmailTaken)
+			return
+		}
+		WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "email change reverted"}, nil)
+}
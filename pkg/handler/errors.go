@@ -1,6 +1,10 @@
 package handler
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/josuebrunel/ezauth/pkg/service"
+)
 
 var (
 	ErrInvalidRequestBody         = errors.New("invalid request body")
@@ -16,9 +20,25 @@ var (
 	ErrInvalidCredentials        = errors.New("invalid email or password")
 	ErrCouldNotRetrieveUser      = errors.New("could not retrieve user")
 	ErrCouldNotRevokeToken       = errors.New("could not revoke token")
+	ErrCouldNotUpdateUser        = errors.New("could not update user")
 	ErrCouldNotDeleteUser        = errors.New("could not delete user")
+	ErrCouldNotRestoreUser       = errors.New("could not restore user")
 	ErrCouldNotProcessPasswordReset = errors.New("could not process password reset request")
 	ErrCouldNotProcessPasswordless = errors.New("could not process passwordless request")
+	ErrCouldNotProcessEmailVerification = errors.New("could not process email verification request")
+	ErrCouldNotProcessEmailChange = errors.New("could not process email change request")
+	ErrCouldNotChangePassword    = errors.New("could not change password")
 	ErrUserIDNotFoundInContext   = errors.New("user id not found in context")
-	ErrUnexpectedSigningMethod   = errors.New("unexpected signing method")
+	ErrClaimsNotFoundInContext   = errors.New("claims not found in context")
+	ErrCouldNotListSessions      = errors.New("could not list sessions")
+	ErrCouldNotListUsers         = errors.New("could not list users")
+	ErrInvalidSince              = errors.New("since must be an RFC 3339 time")
+	ErrInsufficientRole          = errors.New("insufficient role")
+	ErrInsufficientPermission    = errors.New("insufficient permission")
+	ErrInvalidCSRFToken          = errors.New("invalid csrf token")
+	ErrSessionNotFound           = service.ErrSessionNotFound
+	ErrEmailNotVerified          = service.ErrEmailNotVerified
+	ErrEmailTaken                = service.ErrEmailTaken
+	ErrUserDeleted               = service.ErrUserDeleted
+	ErrUnexpectedSigningMethod   = service.ErrUnexpectedSigningMethod
 )
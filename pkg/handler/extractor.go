@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TokenExtractor finds the access token of a request.
+// It returns an empty token when the request does not carry one in the place it looks at,
+// and an error when it does but the token cannot be used.
+type TokenExtractor interface {
+	ExtractToken(r *http.Request) (string, error)
+}
+
+// TokenExtractorFunc adapts a function to the TokenExtractor interface.
+type TokenExtractorFunc func(r *http.Request) (string, error)
+
+// ExtractToken calls f(r).
+func (f TokenExtractorFunc) ExtractToken(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// TokenExtractors chains extractors: the first one finding a token or failing wins.
+type TokenExtractors []TokenExtractor
+
+// ExtractToken tries each extractor in order.
+func (extractors TokenExtractors) ExtractToken(r *http.Request) (string, error) {
+	for _, extractor := range extractors {
+		token, err := extractor.ExtractToken(r)
+		if err != nil || token != "" {
+			return token, err
+		}
+	}
+	return "", nil
+}
+
+// HeaderTokenExtractor reads the token from a header with an authentication scheme,
+// such as "Authorization: Bearer <token>". Headers with another scheme are ignored.
+// An empty Header defaults to Authorization, an empty Scheme to Bearer.
+type HeaderTokenExtractor struct {
+	Header string
+	Scheme string
+}
+
+// ExtractToken implements TokenExtractor.
+func (e HeaderTokenExtractor) ExtractToken(r *http.Request) (string, error) {
+	header, scheme := e.Header, e.Scheme
+	if header == "" {
+		header = "Authorization"
+	}
+	if scheme == "" {
+		scheme = "Bearer"
+	}
+	token, ok := strings.CutPrefix(r.Header.Get(header), scheme+" ")
+	if !ok {
+		return "", nil
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// CookieTokenExtractor reads the token from a cookie.
+// Browsers send cookies with cross-site requests too, so state-changing requests must carry
+// the double-submit CSRF token of cookie mode, unless SkipCSRF is set.
+type CookieTokenExtractor struct {
+	Name     string
+	SkipCSRF bool
+}
+
+// ExtractToken implements TokenExtractor.
+func (e CookieTokenExtractor) ExtractToken(r *http.Request) (string, error) {
+	token := cookieValue(r, e.Name)
+	if token != "" && !e.SkipCSRF && !validCSRF(r) {
+		return "", ErrInvalidCSRFToken
+	}
+	return token, nil
+}
+
+// QueryTokenExtractor reads the token from a query parameter, such as ?access_token=<token>.
+// Query strings end up in logs and browser history, so only use it for clients that cannot do better.
+type QueryTokenExtractor struct {
+	Param string
+}
+
+// ExtractToken implements TokenExtractor.
+func (e QueryTokenExtractor) ExtractToken(r *http.Request) (string, error) {
+	return r.URL.Query().Get(e.Param), nil
+}
+
+// WebSocketProtocolTokenExtractor reads the token from the Sec-WebSocket-Protocol header,
+// the only header browsers let WebSocket clients set. The client offers a subprotocol made of
+// Prefix followed by the token, such as "access_token.<token>".
+// The WebSocket server must not select that subprotocol in its response.
+type WebSocketProtocolTokenExtractor struct {
+	Prefix string
+}
+
+// ExtractToken implements TokenExtractor.
+func (e WebSocketProtocolTokenExtractor) ExtractToken(r *http.Request) (string, error) {
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if token, ok := strings.CutPrefix(strings.TrimSpace(protocol), e.Prefix); ok && token != "" {
+				return token, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// WithTokenExtractors sets where AuthMiddleware and OptionalAuthMiddleware look for the access token,
+// in order. It replaces the default chain: the Authorization bearer header,
+// followed by the access token cookie in cookie mode.
+func WithTokenExtractors(extractors ...TokenExtractor) HandlerOption {
+	return func(h *Handler) {
+		h.extractor = TokenExtractors(extractors)
+	}
+}
+
+// defaultTokenExtractor returns the extractors used without WithTokenExtractors.
+func (h *Handler) defaultTokenExtractor() TokenExtractor {
+	extractors := TokenExtractors{HeaderTokenExtractor{}}
+	if h.cookieMode() {
+		extractors = append(extractors, CookieTokenExtractor{Name: AccessTokenCookie})
+	}
+	return extractors
+}
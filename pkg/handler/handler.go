@@ -4,11 +4,15 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/config"
 	"github.com/josuebrunel/ezauth/pkg/service"
 	"github.com/josuebrunel/gopkg/xlog"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -17,7 +21,11 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("userID")
+const (
+	userContextKey     = contextKey("userID")
+	claimsContextKey   = contextKey("claims")
+	userMemoContextKey = contextKey("user")
+)
 
 type LogoutRequest struct {
 	RefreshToken string `json:"refresh_token"`
@@ -29,9 +37,10 @@ type RefreshTokenRequest struct {
 
 // Handler handles all authentication-related HTTP requests.
 type Handler struct {
-	path string
-	r    *chi.Mux
-	svc  *service.Auth
+	path      string
+	r         *chi.Mux
+	svc       *service.Auth
+	extractor TokenExtractor
 }
 
 // HandlerOption defines a functional option for configuring the Handler.
@@ -44,6 +53,14 @@ func WithRouter(r *chi.Mux) HandlerOption {
 	}
 }
 
+// WithFeatures turns auth methods and routes on or off, overriding the Features configuration.
+// The service shares the configuration and refuses the disabled flows too.
+func WithFeatures(features config.Features) HandlerOption {
+	return func(h *Handler) {
+		h.svc.Cfg.Features = features
+	}
+}
+
 // New creates a new Handler with the given service and path.
 // path is the base URL path where the authentication routes will be mounted.
 // @title EzAuth API
@@ -56,7 +73,6 @@ func WithRouter(r *chi.Mux) HandlerOption {
 func New(svc *service.Auth, path string, options ...HandlerOption) *Handler {
 	h := &Handler{
 		path: path,
-		r:    chi.NewRouter(),
 		svc:  svc,
 	}
 
@@ -64,8 +80,13 @@ func New(svc *service.Auth, path string, options ...HandlerOption) *Handler {
 		opt(h)
 	}
 
+	if h.extractor == nil {
+		h.extractor = h.defaultTokenExtractor()
+	}
+
 	// Default middlewares if router was newly created
-	if len(options) == 0 {
+	if h.r == nil {
+		h.r = chi.NewRouter()
 		h.r.Use(middleware.Logger)
 		h.r.Use(middleware.RequestID)
 		h.r.Use(middleware.RealIP)
@@ -73,7 +94,10 @@ func New(svc *service.Auth, path string, options ...HandlerOption) *Handler {
 	}
 
 	h.r.Get("/ping", h.Ping)
-	h.r.Get("/swagger/*", httpSwagger.WrapHandler)
+	h.r.Get("/.well-known/jwks.json", h.JWKS)
+	if !h.svc.Cfg.Features.DisableSwagger {
+		h.r.Get("/swagger/*", httpSwagger.WrapHandler)
+	}
 
 	// Initialize routes
 	routePath := "/" + h.path
@@ -81,23 +105,55 @@ func New(svc *service.Auth, path string, options ...HandlerOption) *Handler {
 		routePath = "/"
 	}
 	h.r.Route(routePath, func(r chi.Router) {
-		// Public routes
-		r.Post("/register", h.Register)
-		r.Post("/login", h.Login)
+		// Public routes, left out when their feature is disabled
+		if h.svc.RegistrationEnabled() && h.svc.PasswordLoginEnabled() {
+			r.Post("/register", h.Register)
+		}
+		if h.svc.PasswordLoginEnabled() {
+			r.Post("/login", h.Login)
+		}
 		r.Post("/token/refresh", h.RefreshToken)
-		r.Post("/password-reset/request", h.PasswordResetRequest)
-		r.Post("/password-reset/confirm", h.PasswordResetConfirm)
-		r.Post("/passwordless/request", h.PasswordlessRequest)
-		r.Get("/passwordless/login", h.PasswordlessLogin)
-		r.Get("/oauth2/{provider}/login", h.OAuth2Login)
-		r.Get("/oauth2/{provider}/callback", h.OAuth2Callback)
+		if h.svc.PasswordResetEnabled() {
+			r.Post("/password-reset/request", h.PasswordResetRequest)
+			r.Post("/password-reset/confirm", h.PasswordResetConfirm)
+		}
+		r.Post("/email/verify", h.EmailVerify)
+		r.Post("/email/verify/resend", h.EmailVerifyResend)
+		r.Get("/email/change/confirm", h.EmailChangeConfirmLink)
+		r.Post("/email/change/confirm", h.EmailChangeConfirm)
+		r.Get("/email/change/revert", h.EmailChangeRevertLink)
+		r.Post("/email/change/revert", h.EmailChangeRevert)
+		r.Get("/user/restore", h.RestoreUser)
+		if h.svc.PasswordlessEnabled() {
+			r.Post("/passwordless/request", h.PasswordlessRequest)
+			r.Get("/passwordless/login", h.PasswordlessLogin)
+		}
+		if h.svc.OAuth2Enabled() {
+			r.Get("/oauth2/{provider}/login", h.OAuth2Login)
+			r.Get("/oauth2/{provider}/callback", h.OAuth2Callback)
+		}
+
+		// Client authenticated routes
+		r.Post("/introspect", h.Introspect)
+		r.Post("/revoke", h.Revoke)
 
 		// Protected routes
 		r.Group(func(r chi.Router) {
 			r.Use(h.AuthMiddleware)
 			r.Get("/userinfo", h.UserInfo)
 			r.Post("/logout", h.Logout)
+			r.Post("/logout/all", h.LogoutAll)
+			r.Patch("/user", h.UpdateUser)
 			r.Delete("/user", h.DeleteUser)
+			r.Get("/sessions", h.SessionList)
+			r.Delete("/sessions/{id}", h.SessionRevoke)
+			r.Post("/email/change", h.EmailChange)
+			if h.svc.PasswordLoginEnabled() {
+				r.Post("/password/change", h.PasswordChange)
+			}
+			if role := h.svc.Cfg.Activity.AdminRole; role != "" {
+				r.With(h.RequireRole(role)).Get("/users/inactive", h.UserListInactive)
+			}
 		})
 	})
 
@@ -137,14 +193,28 @@ func (h *Handler) Ping(w http.ResponseWriter, r *http.Request) {
 	WriteJSONResponse(w, http.StatusOK, "pong", nil)
 }
 
+// JWKS serves the public keys used to verify access tokens.
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens signed with RS256, ES256 or EdDSA. Empty for HS256.
+// @Tags system
+// @Produce json
+// @Success 200 {object} service.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.svc.JWKS())
+}
+
 // Register handles user registration.
 // @Summary Register a new user
-// @Description Register a new user with basic authentication
+// @Description Register a new user with basic authentication. A verification token is emailed to the user. When email verification is required, no tokens are issued until the email is verified.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body service.RequestBasicAuth true "Registration Request"
 // @Success 201 {object} ApiResponse[service.TokenResponse]
+// @Success 202 {object} ApiResponse[map[string]string]
 // @Failure 400 {object} ApiResponse[string]
 // @Failure 500 {object} ApiResponse[string]
 // @Router /auth/register [post]
@@ -157,22 +227,32 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.svc.UserCreate(r.Context(), &req)
 	if err != nil {
+		if errors.Is(err, service.ErrPasswordTooShort) || errors.Is(err, service.ErrPasswordTooLong) ||
+			errors.Is(err, service.ErrInvalidLocale) || errors.Is(err, service.ErrInvalidTimezone) {
+			WriteJSONResponseError(w, http.StatusBadRequest, err)
+			return
+		}
 		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotCreateUser)
 		return
 	}
 
-	tokenResp, err := h.svc.TokenCreate(r.Context(), user)
+	if h.svc.Cfg.Features.RequireEmailVerification {
+		WriteJSONResponse(w, http.StatusAccepted, map[string]string{"message": "verification email sent"}, nil)
+		return
+	}
+
+	tokenResp, err := h.svc.TokenCreate(r.Context(), user, clientInfo(r))
 	if err != nil {
 		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotCreateToken)
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusCreated, tokenResp, nil)
+	h.writeTokenResponse(w, http.StatusCreated, tokenResp)
 }
 
 // Login handles user login and returns access and refresh tokens.
 // @Summary Login user
-// @Description Login with email and password
+// @Description Login with email and password. When email verification is required, unverified users get 403 "email not verified".
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -180,6 +260,7 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} ApiResponse[service.TokenResponse]
 // @Failure 400 {object} ApiResponse[string]
 // @Failure 401 {object} ApiResponse[string]
+// @Failure 403 {object} ApiResponse[string]
 // @Failure 500 {object} ApiResponse[string]
 // @Router /auth/login [post]
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
@@ -190,23 +271,31 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := h.svc.UserAuthenticate(r.Context(), req)
+	if errors.Is(err, service.ErrEmailNotVerified) {
+		WriteJSONResponseError(w, http.StatusForbidden, ErrEmailNotVerified)
+		return
+	}
+	if errors.Is(err, service.ErrUserDeleted) {
+		WriteJSONResponseError(w, http.StatusForbidden, ErrUserDeleted)
+		return
+	}
 	if err != nil {
 		WriteJSONResponseError(w, http.StatusUnauthorized, ErrInvalidCredentials)
 		return
 	}
 
-	tokenResp, err := h.svc.TokenCreate(r.Context(), user)
+	tokenResp, err := h.svc.TokenCreate(r.Context(), user, clientInfo(r))
 	if err != nil {
 		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotCreateToken)
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, tokenResp, nil)
+	h.writeTokenResponse(w, http.StatusOK, tokenResp)
 }
 
 // RefreshToken handles token refreshing using a valid refresh token.
 // @Summary Refresh access token
-// @Description Get a new access token using a refresh token
+// @Description Get a new access token using a refresh token. In cookie mode, the refresh token cookie is used instead of the body and the X-CSRF-Token header is required.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -216,24 +305,42 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 // @Failure 401 {object} ApiResponse[string]
 // @Router /auth/token/refresh [post]
 func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
-	var req RefreshTokenRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteJSONResponseError(w, http.StatusBadRequest, ErrInvalidRequestBody)
-		return
-	}
-
-	if req.RefreshToken == "" {
-		WriteJSONResponseError(w, http.StatusBadRequest, ErrRefreshTokenRequired)
+	refreshToken, status, err := h.requestRefreshToken(r)
+	if err != nil {
+		WriteJSONResponseError(w, status, err)
 		return
 	}
 
-	tokenResp, err := h.svc.TokenRefresh(r.Context(), req.RefreshToken)
+	tokenResp, err := h.svc.TokenRefresh(r.Context(), refreshToken, clientInfo(r))
 	if err != nil {
 		WriteJSONResponseError(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, tokenResp, nil)
+	h.writeTokenResponse(w, http.StatusOK, tokenResp)
+}
+
+// requestRefreshToken returns the refresh token of a refresh or logout request,
+// read from the refresh token cookie in cookie mode or from the JSON body.
+// It returns the status and error to respond with otherwise.
+func (h *Handler) requestRefreshToken(r *http.Request) (string, int, error) {
+	if h.cookieMode() {
+		if token := cookieValue(r, RefreshTokenCookie); token != "" {
+			if !validCSRF(r) {
+				return "", http.StatusForbidden, ErrInvalidCSRFToken
+			}
+			return token, http.StatusOK, nil
+		}
+	}
+
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return "", http.StatusBadRequest, ErrInvalidRequestBody
+	}
+	if req.RefreshToken == "" {
+		return "", http.StatusBadRequest, ErrRefreshTokenRequired
+	}
+	return req.RefreshToken, http.StatusOK, nil
 }
 
 // UserInfo returns information about the currently authenticated user.
@@ -246,15 +353,56 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} ApiResponse[string]
 // @Router /auth/userinfo [get]
 func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	if _, err := GetUserID(r.Context()); err != nil {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrUserNotFoundInContext)
+		return
+	}
+
+	user, err := GetUser(r.Context())
+	if err != nil {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotRetrieveUser)
+		return
+	}
+
+	user.PasswordHash = ""
+	WriteJSONResponse(w, http.StatusOK, user, nil)
+}
+
+// UpdateUser updates the profile of the currently authenticated user.
+// @Summary Update user
+// @Description Update the name, locale, timezone and user metadata of the authenticated user. Email, roles, provider and app metadata cannot be changed
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body service.RequestUserUpdate true "User Update Request"
+// @Security BearerAuth
+// @Success 200 {object} ApiResponse[models.User]
+// @Failure 400 {object} ApiResponse[string]
+// @Failure 500 {object} ApiResponse[string]
+// @Router /auth/user [patch]
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(userContextKey).(string)
 	if !ok {
 		WriteJSONResponseError(w, http.StatusInternalServerError, ErrUserNotFoundInContext)
 		return
 	}
 
-	user, err := h.svc.Repo.UserGetByID(r.Context(), userID)
+	// Fields users may not change, such as email or roles, are rejected rather than ignored
+	var req service.RequestUserUpdate
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, fmt.Errorf("%w: %v", ErrInvalidRequestBody, err))
+		return
+	}
+
+	user, err := h.svc.UserProfileUpdate(r.Context(), userID, req)
 	if err != nil {
-		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotRetrieveUser)
+		if errors.Is(err, service.ErrInvalidLocale) || errors.Is(err, service.ErrInvalidTimezone) {
+			WriteJSONResponseError(w, http.StatusBadRequest, err)
+			return
+		}
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotUpdateUser)
 		return
 	}
 
@@ -262,9 +410,9 @@ func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
 	WriteJSONResponse(w, http.StatusOK, user, nil)
 }
 
-// Logout handles user logout by revoking the refresh token.
+// Logout handles user logout by revoking the refresh token and the access token of the request.
 // @Summary Logout user
-// @Description Revoke the user's refresh token
+// @Description Revoke the user's refresh token and the access token used for the request. In cookie mode, the refresh token cookie is used instead of the body.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -275,28 +423,65 @@ func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} ApiResponse[string]
 // @Router /auth/logout [post]
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
-	var req LogoutRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteJSONResponseError(w, http.StatusBadRequest, ErrInvalidRequestBody)
+	refreshToken, status, err := h.requestRefreshToken(r)
+	if err != nil {
+		WriteJSONResponseError(w, status, err)
 		return
 	}
 
-	if req.RefreshToken == "" {
-		WriteJSONResponseError(w, http.StatusBadRequest, ErrRefreshTokenRequired)
+	if err := h.svc.TokenRevoke(r.Context(), refreshToken); err != nil {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotRevokeToken)
+		return
+	}
+
+	// Revoke the access token used for this request as well
+	if claims, ok := r.Context().Value(claimsContextKey).(jwt.MapClaims); ok {
+		if err := h.svc.AccessTokenRevokeClaims(r.Context(), claims); err != nil {
+			WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotRevokeToken)
+			return
+		}
+	}
+
+	h.clearTokenCookies(w)
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "logged out successfully"}, nil)
+}
+
+// LogoutAll logs the user out of every device.
+// @Summary Logout from all devices
+// @Description Revoke all refresh tokens of the user and every access token issued so far
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 500 {object} ApiResponse[string]
+// @Router /auth/logout/all [post]
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrUserNotFoundInContext)
 		return
 	}
 
-	if err := h.svc.TokenRevoke(r.Context(), req.RefreshToken); err != nil {
+	if err := h.svc.SessionRevokeAll(r.Context(), userID); err != nil {
 		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotRevokeToken)
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "logged out successfully"}, nil)
+	// Tokens issued in the current second survive the per-user revocation, revoke this one explicitly
+	if claims, ok := r.Context().Value(claimsContextKey).(jwt.MapClaims); ok {
+		if err := h.svc.AccessTokenRevokeClaims(r.Context(), claims); err != nil {
+			WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotRevokeToken)
+			return
+		}
+	}
+
+	h.clearTokenCookies(w)
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "logged out of all devices"}, nil)
 }
 
 // DeleteUser handles user account deletion.
 // @Summary Delete user
-// @Description Delete the authenticated user's account
+// @Description Schedule the deletion of the authenticated user's account and log them out of every device. The account can be restored with the link emailed to the user until the grace period is over, then it is purged.
 // @Tags user
 // @Produce json
 // @Security BearerAuth
@@ -310,12 +495,45 @@ func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.svc.Repo.UserDelete(r.Context(), userID); err != nil {
+	if err := h.svc.UserDelete(r.Context(), userID); err != nil {
 		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotDeleteUser)
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "user deleted successfully"}, nil)
+	// Tokens issued in the current second survive the per-user revocation, revoke this one explicitly
+	if claims, ok := r.Context().Value(claimsContextKey).(jwt.MapClaims); ok {
+		if err := h.svc.AccessTokenRevokeClaims(r.Context(), claims); err != nil {
+			WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotRevokeToken)
+			return
+		}
+	}
+
+	h.clearTokenCookies(w)
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "user scheduled for deletion"}, nil)
+}
+
+// RestoreUser handles the restore link sent when an account is deleted.
+// @Summary Restore user
+// @Description Cancel the deletion of an account within the grace period. The user has to log in again.
+// @Tags user
+// @Produce json
+// @Param token query string true "Account Restore Token"
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 400 {object} ApiResponse[string]
+// @Router /auth/user/restore [get]
+func (h *Handler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrTokenRequired)
+		return
+	}
+
+	if _, err := h.svc.UserRestore(r.Context(), token); err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "user restored successfully"}, nil)
 }
 
 // PasswordResetRequest handles the request for a password reset link.
@@ -369,6 +587,62 @@ func (h *Handler) PasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
 	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "password has been reset successfully"}, nil)
 }
 
+// EmailVerify handles the confirmation of a user's email address.
+// @Summary Verify email
+// @Description Verify the email address of a user with the token sent via email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body service.RequestEmailVerificationConfirm true "Email Verification"
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 400 {object} ApiResponse[string]
+// @Router /auth/email/verify [post]
+func (h *Handler) EmailVerify(w http.ResponseWriter, r *http.Request) {
+	var req service.RequestEmailVerificationConfirm
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrInvalidRequestBody)
+		return
+	}
+
+	if req.Token == "" {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrTokenRequired)
+		return
+	}
+
+	if _, err := h.svc.EmailVerificationConfirm(r.Context(), req); err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "email verified successfully"}, nil)
+}
+
+// EmailVerifyResend handles the request for a new verification email.
+// @Summary Resend verification email
+// @Description Send a new verification token to the user's email, if the account exists and is not verified yet
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body service.RequestEmailVerification true "Verification Email Request"
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 400 {object} ApiResponse[string]
+// @Failure 500 {object} ApiResponse[string]
+// @Router /auth/email/verify/resend [post]
+func (h *Handler) EmailVerifyResend(w http.ResponseWriter, r *http.Request) {
+	var req service.RequestEmailVerification
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.svc.EmailVerificationResend(r.Context(), req); err != nil {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotProcessEmailVerification)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "verification email sent"}, nil)
+}
+
 // PasswordlessRequest handles the request for a magic login link.
 // @Summary Request magic link
 // @Description Send a magic login link to the user's email
@@ -412,11 +686,11 @@ func (h *Handler) PasswordlessLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenResp, err := h.svc.PasswordlessLogin(r.Context(), token)
+	tokenResp, err := h.svc.PasswordlessLogin(r.Context(), token, clientInfo(r))
 	if err != nil {
 		WriteJSONResponseError(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, tokenResp, nil)
+	h.writeTokenResponse(w, http.StatusOK, tokenResp)
 }
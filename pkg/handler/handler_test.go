@@ -2,10 +2,14 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,7 +20,7 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func setupTestHandler(t *testing.T) *Handler {
+func setupTestHandler(t *testing.T, opts ...func(*config.Config)) *Handler {
 	// Use in-memory SQLite database
 	dsn := fmt.Sprintf("file:%d?mode=memory&cache=shared", time.Now().UnixNano())
 	cfg := &config.Config{
@@ -27,6 +31,9 @@ func setupTestHandler(t *testing.T) *Handler {
 		JWTSecret: "test-secret",
 		Addr:      ":8080",
 	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
 	authSvc, err := service.NewFromConfig(cfg, "auth")
 	if err != nil {
 		t.Fatalf("failed to create auth service: %v", err)
@@ -40,6 +47,21 @@ func setupTestHandler(t *testing.T) *Handler {
 	return New(authSvc, "auth")
 }
 
+// createTestUser creates a user directly in the repository and returns a token pair for it.
+func createTestUser(t *testing.T, h *Handler, email, roles string) *service.TokenResponse {
+	t.Helper()
+	ctx := context.Background()
+	user, err := h.svc.Repo.UserCreate(ctx, &models.User{Email: email, Provider: "local", Roles: models.ParseRoles(roles)})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+	tokens, err := h.svc.TokenCreate(ctx, user)
+	if err != nil {
+		t.Fatalf("failed to create tokens: %v", err)
+	}
+	return tokens
+}
+
 // Helper struct to decode responses in tests
 type testResponse[T any] struct {
 	Error any `json:"error"`
@@ -173,6 +195,27 @@ func TestHandler_RegisterAndLoginFlow(t *testing.T) {
 		if w.Code != http.StatusOK {
 			t.Errorf("expected status 200, got %d: %s", w.Code, w.Body.String())
 		}
+
+		// The access token used to log out is revoked
+		req = httptest.NewRequest(http.MethodGet, "/auth/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401 after logout, got %d: %s", w.Code, w.Body.String())
+		}
+
+		body, _ = json.Marshal(map[string]any{"email": email, "password": password})
+		req = httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		var resp testResponse[service.TokenResponse]
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		accessToken = resp.Data.AccessToken
 	})
 
 	// 6. Delete User
@@ -189,6 +232,37 @@ func TestHandler_RegisterAndLoginFlow(t *testing.T) {
 	})
 }
 
+func TestHandler_RegisterIgnoresRoles(t *testing.T) {
+	h := setupTestHandler(t)
+
+	body := `{"email": "register-roles@example.com", "password": "password123", "roles": ["admin"]}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp testResponse[service.TokenResponse]
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	user, err := h.svc.Repo.UserGetByEmail(context.Background(), "register-roles@example.com")
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	if len(user.Roles) != 0 {
+		t.Errorf("expected the user to have no roles, got %v", user.Roles)
+	}
+	claims, err := h.svc.AccessTokenParse(resp.Data.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse access token: %v", err)
+	}
+	if roles := service.NewClaims(claims).Roles; len(roles) != 0 {
+		t.Errorf("expected the access token to carry no roles, got %v", roles)
+	}
+}
+
 func TestHandler_PasswordReset(t *testing.T) {
 	h := setupTestHandler(t)
 	email := "reset@example.com"
@@ -213,9 +287,9 @@ func TestHandler_PasswordReset(t *testing.T) {
 		t.Fatalf("expected 200, got %d", w.Code)
 	}
 
-	// 3. Get token from mock mailer
+	// 3. Get token from mock mailer, registration sent a verification email first
 	mockMailer := h.svc.Mailer.(*service.MockMailer)
-	sentBody := mockMailer.SentEmails[0]["body"]
+	sentBody := mockMailer.SentEmails[len(mockMailer.SentEmails)-1]["body"]
 	tokenValue := sentBody[len(sentBody)-64:]
 
 	// 4. Confirm reset
@@ -307,3 +381,1051 @@ func TestHandler_Unauthorized(t *testing.T) {
 		}
 	})
 }
+
+func TestHandler_JWKS(t *testing.T) {
+	h := setupTestHandler(t, func(cfg *config.Config) {
+		cfg.JWT.Algorithm = service.AlgES256
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var jwks service.JWKSet
+	if err := json.NewDecoder(w.Body).Decode(&jwks); err != nil {
+		t.Fatalf("failed to decode jwks: %v", err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Alg != service.AlgES256 || jwks.Keys[0].Crv != "P-256" {
+		t.Fatalf("unexpected jwks: %+v", jwks)
+	}
+
+	// Tokens signed with the asymmetric key are accepted by the middleware
+	body, _ := json.Marshal(map[string]any{"email": "jwks@example.com", "password": "password123"})
+	req = httptest.NewRequest(http.MethodPost, "/auth/register", bytes.NewBuffer(body))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var resp testResponse[service.TokenResponse]
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/auth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Data.AccessToken)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandler_Introspection(t *testing.T) {
+	h := setupTestHandler(t, func(cfg *config.Config) {
+		cfg.Introspection = config.Introspection{ClientID: "gateway", ClientSecret: "gateway-secret", AdminRole: "admin"}
+	})
+
+	user := createTestUser(t, h, "introspect@example.com", "user,editor")
+	admin := createTestUser(t, h, "introspect-admin@example.com", "admin")
+
+	introspect := func(t *testing.T, form url.Values, auth func(*http.Request)) (*httptest.ResponseRecorder, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/auth/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if auth != nil {
+			auth(req)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		var resp map[string]any
+		json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&resp)
+		return w, resp
+	}
+	basicAuth := func(req *http.Request) { req.SetBasicAuth("gateway", "gateway-secret") }
+
+	t.Run("AccessToken", func(t *testing.T) {
+		w, resp := introspect(t, url.Values{"token": {user.AccessToken}}, basicAuth)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if resp["active"] != true || resp["username"] != "introspect@example.com" || resp["scope"] != "user editor" {
+			t.Errorf("unexpected response: %v", resp)
+		}
+		if resp["token_type"] != service.TokenTypeHintAccessToken || resp["exp"] == nil || resp["sub"] == "" {
+			t.Errorf("unexpected response: %v", resp)
+		}
+	})
+
+	t.Run("RefreshToken", func(t *testing.T) {
+		form := url.Values{"token": {user.RefreshToken}, "token_type_hint": {"refresh_token"}, "client_id": {"gateway"}, "client_secret": {"gateway-secret"}}
+		w, resp := introspect(t, form, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if resp["active"] != true || resp["token_type"] != service.TokenTypeHintRefreshToken {
+			t.Errorf("unexpected response: %v", resp)
+		}
+	})
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		_, resp := introspect(t, url.Values{"token": {"unknown"}}, basicAuth)
+		if len(resp) != 1 || resp["active"] != false {
+			t.Errorf("expected only active=false, got %v", resp)
+		}
+	})
+
+	t.Run("AdminBearer", func(t *testing.T) {
+		w, resp := introspect(t, url.Values{"token": {user.AccessToken}}, func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+		})
+		if w.Code != http.StatusOK || resp["active"] != true {
+			t.Errorf("expected active token, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		for name, auth := range map[string]func(*http.Request){
+			"NoCredentials": nil,
+			"WrongSecret":   func(req *http.Request) { req.SetBasicAuth("gateway", "wrong") },
+			"NonAdmin":      func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+user.AccessToken) },
+		} {
+			w, resp := introspect(t, url.Values{"token": {user.AccessToken}}, auth)
+			if w.Code != http.StatusUnauthorized || resp["error"] != "invalid_client" {
+				t.Errorf("%s: expected 401 invalid_client, got %d: %s", name, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("MissingToken", func(t *testing.T) {
+		w, resp := introspect(t, url.Values{}, basicAuth)
+		if w.Code != http.StatusBadRequest || resp["error"] != "invalid_request" {
+			t.Errorf("expected 400 invalid_request, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		for _, token := range []string{user.AccessToken, user.RefreshToken, "unknown"} {
+			req := httptest.NewRequest(http.MethodPost, "/auth/revoke", strings.NewReader(url.Values{"token": {token}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			basicAuth(req)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+		}
+
+		for _, token := range []string{user.AccessToken, user.RefreshToken} {
+			if _, resp := introspect(t, url.Values{"token": {token}}, basicAuth); resp["active"] != false {
+				t.Errorf("expected revoked token to be inactive, got %v", resp)
+			}
+		}
+	})
+}
+
+func TestHandler_IntrospectionDefaults(t *testing.T) {
+	h := setupTestHandler(t, func(cfg *config.Config) {
+		cfg.Introspection = config.Introspection{ClientID: "gateway", ClientSecret: "gateway-secret"}
+	})
+	victim := createTestUser(t, h, "introspect-victim@example.com", "")
+
+	// Without an admin role configured, bearer tokens are refused, even those of admins
+	admin := createTestUser(t, h, "introspect-defaults-admin@example.com", "admin")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{"email": "introspect-registered@example.com", "password": "password123", "roles": ["admin"]}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var registered testResponse[service.TokenResponse]
+	if err := json.NewDecoder(rr.Body).Decode(&registered); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	for name, accessToken := range map[string]string{"Registered": registered.Data.AccessToken, "Admin": admin.AccessToken} {
+		for _, path := range []string{"/auth/introspect", "/auth/revoke"} {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(url.Values{"token": {victim.RefreshToken}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s: expected status 401, got %d: %s", name, path, rr.Code, rr.Body.String())
+			}
+		}
+	}
+
+	if _, err := h.svc.TokenRefresh(context.Background(), victim.RefreshToken); err != nil {
+		t.Errorf("expected the token of the victim to be left alone, got %v", err)
+	}
+}
+
+func TestHandler_Sessions(t *testing.T) {
+	h := setupTestHandler(t)
+	tokens := createTestUser(t, h, "sessions@example.com", "")
+
+	// Log in from a second device through the refresh endpoint to record client info
+	body, _ := json.Marshal(map[string]string{"refresh_token": tokens.RefreshToken})
+	req := httptest.NewRequest(http.MethodPost, "/auth/token/refresh", bytes.NewBuffer(body))
+	req.Header.Set("User-Agent", "test-agent")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var refreshed testResponse[service.TokenResponse]
+	json.NewDecoder(w.Body).Decode(&refreshed)
+	accessToken := refreshed.Data.AccessToken
+
+	var sessions []service.Session
+	t.Run("List", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/auth/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp testResponse[[]service.Session]
+		json.NewDecoder(w.Body).Decode(&resp)
+		sessions = resp.Data
+		if len(sessions) != 1 || !sessions[0].Current || sessions[0].UserAgent != "test-agent" || sessions[0].IP == "" {
+			t.Errorf("unexpected sessions: %+v", sessions)
+		}
+	})
+
+	t.Run("RevokeUnknown", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/auth/sessions/unknown", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/auth/sessions/"+sessions[0].ID, nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		body, _ := json.Marshal(map[string]string{"refresh_token": refreshed.Data.RefreshToken})
+		req = httptest.NewRequest(http.MethodPost, "/auth/token/refresh", bytes.NewBuffer(body))
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected refresh of a revoked session to fail with 401, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestHandler_LogoutAll(t *testing.T) {
+	h := setupTestHandler(t)
+	first := createTestUser(t, h, "logout-all@example.com", "")
+	user, err := h.svc.Repo.UserGetByEmail(context.Background(), "logout-all@example.com")
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	second, err := h.svc.TokenCreate(context.Background(), user)
+	if err != nil {
+		t.Fatalf("failed to create tokens: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/logout/all", nil)
+	req.Header.Set("Authorization", "Bearer "+first.AccessToken)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, refreshToken := range []string{first.RefreshToken, second.RefreshToken} {
+		body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+		req := httptest.NewRequest(http.MethodPost, "/auth/token/refresh", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected refresh to fail with 401, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/auth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+first.AccessToken)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the access token to be revoked, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandler_RequireRole(t *testing.T) {
+	h := setupTestHandler(t)
+	admin := createTestUser(t, h, "roles-admin@example.com", "editor, admin")
+	member := createTestUser(t, h, "roles-member@example.com", "member")
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		token      string
+		wantStatus int
+	}{
+		{"RoleGranted", h.RequireRole("admin"), admin.AccessToken, http.StatusNoContent},
+		{"RoleDenied", h.RequireRole("admin"), member.AccessToken, http.StatusForbidden},
+		{"AnyRoleGranted", h.RequireAnyRole("owner", "member"), member.AccessToken, http.StatusNoContent},
+		{"AnyRoleDenied", h.RequireAnyRole("owner", "billing"), admin.AccessToken, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			h.AuthMiddleware(tt.middleware(ok)).ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("WithoutAuthMiddleware", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.RequireRole("admin")(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", w.Code)
+		}
+	})
+}
+
+func TestHandler_RequirePermission(t *testing.T) {
+	h := setupTestHandler(t)
+	ctx := context.Background()
+
+	if _, err := h.svc.RoleCreate(ctx, "accountant", ""); err != nil {
+		t.Fatalf("failed to create role: %v", err)
+	}
+	if _, err := h.svc.PermissionCreate(ctx, "invoices:read", ""); err != nil {
+		t.Fatalf("failed to create permission: %v", err)
+	}
+	if err := h.svc.RolePermissionGrant(ctx, "accountant", "invoices:read"); err != nil {
+		t.Fatalf("failed to grant permission: %v", err)
+	}
+	accountant := createTestUser(t, h, "permissions-accountant@example.com", "accountant")
+	member := createTestUser(t, h, "permissions-member@example.com", "member")
+
+	// Roles sent on registration must not grant their permissions
+	body := `{"email": "permissions-registered@example.com", "password": "password123", "roles": ["accountant"]}`
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var registered testResponse[service.TokenResponse]
+	if err := json.NewDecoder(rr.Body).Decode(&registered); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name       string
+		permission string
+		token      string
+		wantStatus int
+	}{
+		{"Granted", "invoices:read", accountant.AccessToken, http.StatusNoContent},
+		{"NotGranted", "invoices:write", accountant.AccessToken, http.StatusForbidden},
+		{"NoPermissions", "invoices:read", member.AccessToken, http.StatusForbidden},
+		{"RegisteredWithRole", "invoices:read", registered.Data.AccessToken, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/invoices", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			h.AuthMiddleware(h.RequirePermission(tt.permission)(ok)).ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_RequestContext(t *testing.T) {
+	h := setupTestHandler(t)
+	tokens := createTestUser(t, h, "context@example.com", "admin")
+
+	var (
+		claims      *service.Claims
+		first, next *models.User
+		errs        []error
+	)
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		claims, err = GetClaims(r.Context())
+		errs = append(errs, err)
+		first, err = GetUser(r.Context())
+		errs = append(errs, err)
+		next, err = GetUser(r.Context())
+		errs = append(errs, err)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	h.AuthMiddleware(inner).ServeHTTP(httptest.NewRecorder(), req)
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if claims.Email != "context@example.com" || !claims.Roles.Has("admin") {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if claims.Subject != first.ID || claims.ID == "" || claims.SessionID == "" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if time.Until(claims.ExpiresAt) <= 0 {
+		t.Errorf("expected expiry in the future, got %v", claims.ExpiresAt)
+	}
+	if first != next {
+		t.Error("expected the user to be loaded once per request")
+	}
+
+	t.Run("WithoutAuthMiddleware", func(t *testing.T) {
+		if _, err := GetClaims(context.Background()); !errors.Is(err, ErrClaimsNotFoundInContext) {
+			t.Errorf("expected ErrClaimsNotFoundInContext, got %v", err)
+		}
+		if _, err := GetUser(context.Background()); !errors.Is(err, ErrUserIDNotFoundInContext) {
+			t.Errorf("expected ErrUserIDNotFoundInContext, got %v", err)
+		}
+	})
+}
+
+func TestHandler_Activity(t *testing.T) {
+	h := setupTestHandler(t, func(cfg *config.Config) {
+		cfg.Activity.Interval = time.Nanosecond
+	})
+	tokens := createTestUser(t, h, "activity@example.com", "")
+	if err := h.svc.ActivityFlush(context.Background()); err != nil {
+		t.Fatalf("ActivityFlush() unexpected error: %v", err)
+	}
+
+	user, err := h.svc.Repo.UserGetByEmail(context.Background(), "activity@example.com")
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	if user.LastActiveAt == nil {
+		t.Fatal("expected login to set last_active_at")
+	}
+	loggedInAt := *user.LastActiveAt
+	time.Sleep(10 * time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	h.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+	if err := h.svc.ActivityFlush(context.Background()); err != nil {
+		t.Fatalf("ActivityFlush() unexpected error: %v", err)
+	}
+
+	user, err = h.svc.Repo.UserGetByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	if user.LastActiveAt == nil || !user.LastActiveAt.After(loggedInAt) {
+		t.Errorf("expected authenticated request to update last_active_at after %v, got %v", loggedInAt, user.LastActiveAt)
+	}
+}
+
+func TestHandler_UserListInactive(t *testing.T) {
+	h := setupTestHandler(t, func(cfg *config.Config) {
+		cfg.Activity.AdminRole = "admin"
+	})
+	admin := createTestUser(t, h, "inactive-admin@example.com", "admin")
+	user := createTestUser(t, h, "inactive-user@example.com", "")
+
+	get := func(h *Handler, query, accessToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/users/inactive"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	since := "?since=" + url.QueryEscape(time.Now().Add(time.Minute).UTC().Format(time.RFC3339))
+
+	rr := get(h, since, admin.AccessToken)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp testResponse[[]models.User]
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	found := false
+	for _, u := range resp.Data {
+		found = found || u.Email == "inactive-user@example.com"
+	}
+	if !found {
+		t.Errorf("expected the user not active since to be listed, got %+v", resp.Data)
+	}
+
+	if rr := get(h, since, user.AccessToken); rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 without the admin role, got %d", rr.Code)
+	}
+	if rr := get(h, "?since=yesterday", admin.AccessToken); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid time, got %d", rr.Code)
+	}
+
+	// Without an admin role configured, the route is left out
+	if rr := get(setupTestHandler(t), since, admin.AccessToken); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without an admin role, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Features(t *testing.T) {
+	routes := []struct {
+		method, path string
+	}{
+		{http.MethodPost, "/auth/register"},
+		{http.MethodPost, "/auth/login"},
+		{http.MethodPost, "/auth/password/change"},
+		{http.MethodPost, "/auth/password-reset/request"},
+		{http.MethodPost, "/auth/passwordless/request"},
+		{http.MethodGet, "/auth/oauth2/google/login"},
+		{http.MethodGet, "/swagger/index.html"},
+	}
+	status := func(h *Handler, method, path string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("Enabled", func(t *testing.T) {
+		h := setupTestHandler(t)
+		for _, route := range routes {
+			if code := status(h, route.method, route.path); code == http.StatusNotFound || code == http.StatusMethodNotAllowed {
+				t.Errorf("expected %s %s to be registered, got %d", route.method, route.path, code)
+			}
+		}
+	})
+
+	disabled := config.Features{
+		DisableRegistration:  true,
+		DisablePasswordLogin: true,
+		DisablePasswordReset: true,
+		DisablePasswordless:  true,
+		DisableOAuth2:        true,
+		DisableSwagger:       true,
+	}
+	for name, h := range map[string]*Handler{
+		"Config": setupTestHandler(t, func(cfg *config.Config) { cfg.Features = disabled }),
+		"Option": New(setupTestHandler(t).svc, "auth", WithFeatures(disabled)),
+	} {
+		t.Run("DisabledBy"+name, func(t *testing.T) {
+			for _, route := range routes {
+				if code := status(h, route.method, route.path); code != http.StatusNotFound {
+					t.Errorf("expected %s %s to be removed, got %d", route.method, route.path, code)
+				}
+			}
+			if code := status(h, http.MethodGet, "/ping"); code != http.StatusOK {
+				t.Errorf("expected /ping to stay registered, got %d", code)
+			}
+			if _, err := h.svc.UserCreate(context.Background(), &service.RequestBasicAuth{Email: "features@example.com", Password: "password123"}); !errors.Is(err, service.ErrRegistrationDisabled) {
+				t.Errorf("expected service to refuse registration, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHandler_EmailVerification(t *testing.T) {
+	h := setupTestHandler(t, func(cfg *config.Config) {
+		cfg.Features.RequireEmailVerification = true
+	})
+	mailer := h.svc.Mailer.(*service.MockMailer)
+	email := "verify@example.com"
+	credentials := `{"email": "verify@example.com", "password": "password123"}`
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Registration issues no tokens until the email is verified
+	rr := post("/auth/register", credentials)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "access_token") {
+		t.Errorf("expected no tokens before verification, got %s", rr.Body.String())
+	}
+
+	rr = post("/auth/login", credentials)
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), ErrEmailNotVerified.Error()) {
+		t.Fatalf("expected 403 %q, got %d: %s", ErrEmailNotVerified, rr.Code, rr.Body.String())
+	}
+
+	rr = post("/auth/email/verify/resend", `{"email": "verify@example.com"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	sent := mailer.SentEmails[len(mailer.SentEmails)-1]
+	if sent["to"] != email {
+		t.Fatalf("expected verification email to %s, got %s", email, sent["to"])
+	}
+	token := sent["body"][len(sent["body"])-64:]
+
+	if rr = post("/auth/email/verify", `{"token": "invalid"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid token, got %d", rr.Code)
+	}
+	if rr = post("/auth/email/verify", `{"token": "`+token+`"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr = post("/auth/login", credentials); rr.Code != http.StatusOK {
+		t.Errorf("expected verified user to log in, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_EmailChange(t *testing.T) {
+	h := setupTestHandler(t)
+	mailer := h.svc.Mailer.(*service.MockMailer)
+	tokens := createTestUser(t, h, "change@example.com", "")
+	createTestUser(t, h, "change-taken@example.com", "")
+
+	do := func(method, path, body, accessToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/auth/email/change", `{"email": "changed@example.com"}`, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without a token, got %d", rr.Code)
+	}
+	// A taken email is not disclosed
+	if rr := do(http.MethodPost, "/auth/email/change", `{"email": "change-taken@example.com"}`, tokens.AccessToken); rr.Code != http.StatusOK {
+		t.Errorf("expected status 200 for a taken email, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/auth/email/change", `{"email": "changed@example.com", "password": "wrong"}`, tokens.AccessToken); rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a wrong password, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The session just logged in, so the password is not needed
+	rr := do(http.MethodPost, "/auth/email/change", `{"email": "changed@example.com"}`, tokens.AccessToken)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(mailer.SentEmails) < 2 {
+		t.Fatal("expected confirmation and notice emails to be sent")
+	}
+	confirm := mailer.SentEmails[len(mailer.SentEmails)-2]
+	notice := mailer.SentEmails[len(mailer.SentEmails)-1]
+	if confirm["to"] != "changed@example.com" || notice["to"] != "change@example.com" {
+		t.Fatalf("expected emails to the new and old addresses, got %s and %s", confirm["to"], notice["to"])
+	}
+
+	confirmToken := confirm["body"][len(confirm["body"])-64:]
+	revertToken := notice["body"][len(notice["body"])-64:]
+
+	// Opening the link changes nothing
+	if rr := do(http.MethodGet, "/auth/email/change/confirm?token="+confirmToken, "", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := h.svc.Repo.UserGetByEmail(context.Background(), "changed@example.com"); err == nil {
+		t.Fatal("expected email to be unchanged after opening the link")
+	}
+
+	if rr := do(http.MethodPost, "/auth/email/change/confirm", `{"token": "invalid"}`, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid token, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/auth/email/change/confirm", `{"token": "`+confirmToken+`"}`, ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	user, err := h.svc.Repo.UserGetByEmail(context.Background(), "changed@example.com")
+	if err != nil {
+		t.Fatalf("expected email to be changed: %v", err)
+	}
+
+	if rr := do(http.MethodGet, "/auth/email/change/revert?token="+revertToken, "", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/auth/email/change/revert", `{"token": "`+revertToken+`"}`, ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	user, err = h.svc.Repo.UserGetByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	if user.Email != "change@example.com" {
+		t.Errorf("expected email to be reverted, got %s", user.Email)
+	}
+}
+
+func TestHandler_PasswordChange(t *testing.T) {
+	h := setupTestHandler(t)
+
+	do := func(path, body, accessToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("/auth/register", `{"email": "short@example.com", "password": "short"}`, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a short password, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("/auth/register", `{"email": "bad-locale@example.com", "password": "password123", "locale": "not a locale"}`, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid locale, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("/auth/register", `{"email": "bad-timezone@example.com", "password": "password123", "timezone": "Nowhere/Town"}`, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid timezone, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := do("/auth/register", `{"email": "password-change@example.com", "password": "password123"}`, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp testResponse[service.TokenResponse]
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	accessToken := resp.Data.AccessToken
+
+	if rr := do("/auth/password/change", `{"current_password": "password123", "password": "new-password"}`, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without a token, got %d", rr.Code)
+	}
+	if rr := do("/auth/password/change", `{"current_password": "wrong", "password": "new-password"}`, accessToken); rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a wrong password, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("/auth/password/change", `{"current_password": "password123", "password": "short"}`, accessToken); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a short password, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("/auth/password/change", `{"current_password": "password123", "password": "new-password"}`, accessToken); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := do("/auth/login", `{"email": "password-change@example.com", "password": "new-password"}`, ""); rr.Code != http.StatusOK {
+		t.Errorf("expected login with the new password, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_PasswordChangeDisabled(t *testing.T) {
+	h := setupTestHandler(t, func(cfg *config.Config) {
+		cfg.Features.DisablePasswordLogin = true
+	})
+	tokens := createTestUser(t, h, "password-change-disabled@example.com", "")
+
+	// The route is not registered, but applications may mount the handler themselves
+	req := httptest.NewRequest(http.MethodPost, "/password/change", strings.NewReader(`{"current_password": "password123", "password": "new-password"}`))
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	rr := httptest.NewRecorder()
+	h.AuthMiddleware(http.HandlerFunc(h.PasswordChange)).ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_UpdateUser(t *testing.T) {
+	h := setupTestHandler(t)
+	tokens := createTestUser(t, h, "update@example.com", "user")
+
+	patch := func(body, accessToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/auth/user", strings.NewReader(body))
+		if accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := patch(`{"first_name": "Jane"}`, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without a token, got %d", rr.Code)
+	}
+
+	rr := patch(`{"first_name": "Jane", "locale": "pt-br", "timezone": "America/Sao_Paulo", "user_metadata": {"theme": "dark"}}`, tokens.AccessToken)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp testResponse[models.User]
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Data.FirstName != "Jane" || resp.Data.Locale != "pt-BR" || resp.Data.Timezone != "America/Sao_Paulo" || resp.Data.UserMetadata["theme"] != "dark" {
+		t.Errorf("unexpected user: %+v", resp.Data)
+	}
+
+	for _, body := range []string{
+		`{"locale": "not a locale"}`,
+		`{"timezone": "Nowhere/Town"}`,
+		`{"email": "hijack@example.com"}`,
+		`{"roles": ["admin"]}`,
+		`{"provider": "github"}`,
+		`{"app_metadata": {"plan": "pro"}}`,
+	} {
+		if rr := patch(body, tokens.AccessToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d: %s", body, rr.Code, rr.Body.String())
+		}
+	}
+
+	user, err := h.svc.Repo.UserGetByEmail(context.Background(), "update@example.com")
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	if user.Roles.Has("admin") || user.Provider != "local" || user.AppMetadata["plan"] != nil {
+		t.Errorf("expected protected fields to be unchanged, got %+v", user)
+	}
+}
+
+func TestHandler_DeleteUser(t *testing.T) {
+	h := setupTestHandler(t)
+	mailer := h.svc.Mailer.(*service.MockMailer)
+
+	do := func(method, path, body, accessToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/auth/register", `{"email": "delete@example.com", "password": "password123"}`, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp testResponse[service.TokenResponse]
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if rr := do(http.MethodDelete, "/auth/user", "", resp.Data.AccessToken); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/auth/userinfo", "", resp.Data.AccessToken); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 with the revoked access token, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/auth/login", `{"email": "delete@example.com", "password": "password123"}`, ""); rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 on login, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := do(http.MethodGet, "/auth/user/restore?token=invalid", "", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid token, got %d", rr.Code)
+	}
+	sent := mailer.SentEmails[len(mailer.SentEmails)-1]
+	if sent["to"] != "delete@example.com" {
+		t.Fatalf("expected a restore link to be sent, got %v", sent)
+	}
+	if rr := do(http.MethodGet, "/auth/user/restore?token="+sent["body"][len(sent["body"])-64:], "", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/auth/login", `{"email": "delete@example.com", "password": "password123"}`, ""); rr.Code != http.StatusOK {
+		t.Errorf("expected login after restore, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_OptionalAuthMiddleware(t *testing.T) {
+	h := setupTestHandler(t)
+	tokens := createTestUser(t, h, "optional-auth@example.com", "")
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := GetUserID(r.Context())
+		if err != nil {
+			userID = "anonymous"
+		}
+		w.Write([]byte(userID))
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantAnonymous bool
+	}{
+		{"Anonymous", "", http.StatusOK, true},
+		{"ValidToken", "Bearer " + tokens.AccessToken, http.StatusOK, false},
+		{"InvalidToken", "Bearer invalid", http.StatusUnauthorized, false},
+		{"NotBearer", "Basic dXNlcjpwYXNz", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/feed", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			h.OptionalAuthMiddleware(inner).ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if anonymous := w.Body.String() == "anonymous"; anonymous != tt.wantAnonymous {
+				t.Errorf("expected anonymous %v, got body %q", tt.wantAnonymous, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_CookieMode(t *testing.T) {
+	h := setupTestHandler(t, func(cfg *config.Config) {
+		cfg.Cookie.Enabled = true
+	})
+
+	cookies := map[string]*http.Cookie{}
+	send := func(method, path, csrf string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		if csrf != "" {
+			req.Header.Set(CSRFHeader, csrf)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			cookies[c.Name] = c
+		}
+		return w
+	}
+
+	body, _ := json.Marshal(map[string]string{"email": "cookies@example.com", "password": "password123"})
+	req := httptest.NewRequest(http.MethodPost, "/auth/register", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	for _, c := range w.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+
+	t.Run("TokensInCookies", func(t *testing.T) {
+		var resp testResponse[service.TokenResponse]
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp.Data.AccessToken != "" || resp.Data.RefreshToken != "" {
+			t.Error("expected the tokens to be left out of the body")
+		}
+		for _, name := range []string{AccessTokenCookie, RefreshTokenCookie} {
+			c := cookies[name]
+			if c == nil || c.Value == "" || !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteLaxMode {
+				t.Errorf("expected a secure HttpOnly %s cookie, got %+v", name, c)
+			}
+		}
+		if c := cookies[CSRFCookie]; c == nil || c.Value == "" || c.HttpOnly {
+			t.Errorf("expected a CSRF cookie readable by scripts, got %+v", c)
+		}
+	})
+
+	t.Run("AuthenticatedByCookie", func(t *testing.T) {
+		if w := send(http.MethodGet, "/auth/userinfo", ""); w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		oldRefresh := cookies[RefreshTokenCookie].Value
+		if w := send(http.MethodPost, "/auth/token/refresh", ""); w.Code != http.StatusForbidden {
+			t.Fatalf("expected status 403 without CSRF token, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := send(http.MethodPost, "/auth/token/refresh", cookies[CSRFCookie].Value); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if cookies[RefreshTokenCookie].Value == oldRefresh {
+			t.Error("expected the refresh token cookie to be rotated")
+		}
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		access := *cookies[AccessTokenCookie]
+		if w := send(http.MethodPost, "/auth/logout", "wrong"); w.Code != http.StatusForbidden {
+			t.Fatalf("expected status 403 with a wrong CSRF token, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := send(http.MethodPost, "/auth/logout", cookies[CSRFCookie].Value); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		for _, name := range []string{AccessTokenCookie, RefreshTokenCookie, CSRFCookie} {
+			if c := cookies[name]; c.MaxAge >= 0 || c.Value != "" {
+				t.Errorf("expected the %s cookie to be cleared, got %+v", name, c)
+			}
+		}
+
+		cookies = map[string]*http.Cookie{AccessTokenCookie: &access}
+		if w := send(http.MethodGet, "/auth/userinfo", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected the access token to be revoked, got %d", w.Code)
+		}
+	})
+}
+
+func TestHandler_TokenExtractors(t *testing.T) {
+	h := setupTestHandler(t)
+	tokens := createTestUser(t, h, "extractors@example.com", "")
+	h = New(h.svc, "auth", WithTokenExtractors(
+		HeaderTokenExtractor{Scheme: "Token"},
+		QueryTokenExtractor{Param: "access_token"},
+		WebSocketProtocolTokenExtractor{Prefix: "access_token."},
+	))
+
+	tests := []struct {
+		name       string
+		setup      func(r *http.Request)
+		wantStatus int
+	}{
+		{"LegacyHeader", func(r *http.Request) { r.Header.Set("Authorization", "Token "+tokens.AccessToken) }, http.StatusOK},
+		{"Query", func(r *http.Request) { r.URL.RawQuery = "access_token=" + tokens.AccessToken }, http.StatusOK},
+		{"WebSocketProtocol", func(r *http.Request) {
+			r.Header.Set("Sec-WebSocket-Protocol", "chat, access_token."+tokens.AccessToken)
+		}, http.StatusOK},
+		{"BearerNotConfigured", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+tokens.AccessToken) }, http.StatusUnauthorized},
+		{"Missing", func(r *http.Request) {}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auth/userinfo", nil)
+			tt.setup(req)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("Chain", func(t *testing.T) {
+		failing := TokenExtractorFunc(func(r *http.Request) (string, error) { return "", ErrInvalidToken })
+		chain := TokenExtractors{QueryTokenExtractor{Param: "token"}, failing, HeaderTokenExtractor{}}
+		req := httptest.NewRequest(http.MethodGet, "/?token=abc", nil)
+		if token, err := chain.ExtractToken(req); token != "abc" || err != nil {
+			t.Errorf("expected the first token found, got %q, %v", token, err)
+		}
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer abc")
+		if _, err := chain.ExtractToken(req); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("expected the chain to stop at the failing extractor, got %v", err)
+		}
+	})
+}
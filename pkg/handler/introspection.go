@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/josuebrunel/ezauth/pkg/service"
+)
+
+// OAuth error codes defined by RFC 6749.
+const (
+	oauthErrInvalidRequest = "invalid_request"
+	oauthErrInvalidClient  = "invalid_client"
+)
+
+// OAuthErrorResponse is an OAuth 2.0 error response as defined by RFC 6749.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// Introspect reports whether a token is active.
+// @Summary Token introspection
+// @Description RFC 7662 token introspection for access and refresh tokens. Requires client credentials (HTTP Basic or client_id/client_secret form fields) or, when an admin role is configured, the bearer token of a user with that role. The response is not wrapped in the ApiResponse envelope.
+// @Tags token
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Token to introspect"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Security BearerAuth
+// @Success 200 {object} service.IntrospectionResponse
+// @Failure 400 {object} OAuthErrorResponse
+// @Failure 401 {object} OAuthErrorResponse
+// @Router /auth/introspect [post]
+func (h *Handler) Introspect(w http.ResponseWriter, r *http.Request) {
+	token, ok := h.introspectionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, h.svc.TokenIntrospect(r.Context(), token, r.PostForm.Get("token_type_hint")))
+}
+
+// Revoke revokes a token.
+// @Summary Token revocation
+// @Description RFC 7009 token revocation for access and refresh tokens. Requires client credentials (HTTP Basic or client_id/client_secret form fields) or, when an admin role is configured, the bearer token of a user with that role. Unknown tokens are ignored.
+// @Tags token
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Token to revoke"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Security BearerAuth
+// @Success 200
+// @Failure 400 {object} OAuthErrorResponse
+// @Failure 401 {object} OAuthErrorResponse
+// @Router /auth/revoke [post]
+func (h *Handler) Revoke(w http.ResponseWriter, r *http.Request) {
+	token, ok := h.introspectionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.TokenRevokeAny(r.Context(), token); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, OAuthErrorResponse{Error: "temporarily_unavailable"})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// introspectionRequest parses a form-encoded introspection or revocation request,
+// authorizes the caller and returns the token. It writes the error response otherwise.
+func (h *Handler) introspectionRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, OAuthErrorResponse{Error: oauthErrInvalidRequest, ErrorDescription: "invalid form body"})
+		return "", false
+	}
+
+	if !h.introspectionAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ezauth"`)
+		writeJSON(w, http.StatusUnauthorized, OAuthErrorResponse{Error: oauthErrInvalidClient})
+		return "", false
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, OAuthErrorResponse{Error: oauthErrInvalidRequest, ErrorDescription: "token is required"})
+		return "", false
+	}
+	return token, true
+}
+
+// introspectionAuthorized checks the client credentials or the admin bearer token of the request.
+// Bearer tokens are only accepted when an admin role is configured.
+func (h *Handler) introspectionAuthorized(r *http.Request) bool {
+	cfg := h.svc.Cfg.Introspection
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if cfg.ClientID != "" && cfg.ClientSecret != "" && clientID != "" {
+		return subtle.ConstantTimeCompare([]byte(clientID), []byte(cfg.ClientID)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(clientSecret), []byte(cfg.ClientSecret)) == 1
+	}
+
+	if cfg.AdminRole == "" {
+		return false
+	}
+	tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	claims, err := h.svc.AccessTokenVerify(r.Context(), tokenString)
+	if err != nil {
+		return false
+	}
+	return service.NewClaims(claims).Roles.Has(cfg.AdminRole)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -2,51 +2,80 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"net/http"
-	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
 )
 
 // AuthMiddleware is a middleware that authenticates requests using a JWT bearer token.
+// In cookie mode, requests without an Authorization header are authenticated with the access token cookie.
+// WithTokenExtractors changes where the token is looked for.
 func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			WriteJSONResponseError(w, http.StatusUnauthorized, ErrAuthorizationHeaderRequired)
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			WriteJSONResponseError(w, http.StatusUnauthorized, ErrBearerTokenRequired)
-			return
-		}
+	return h.authMiddleware(next, true)
+}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, ErrUnexpectedSigningMethod
-			}
-			return []byte(h.svc.Cfg.JWTSecret), nil
-		})
+// OptionalAuthMiddleware is a middleware that authenticates requests carrying an access token
+// and lets anonymous requests through, for routes that serve both.
+// Requests with an Authorization header of another scheme than Bearer are anonymous too.
+// Requests with an invalid token are rejected like with AuthMiddleware.
+// Handlers tell anonymous requests apart with GetUserID returning an error.
+func (h *Handler) OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return h.authMiddleware(next, false)
+}
 
+func (h *Handler) authMiddleware(next http.Handler, required bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString, err := h.extractor.ExtractToken(r)
 		if err != nil {
-			WriteJSONResponseError(w, http.StatusUnauthorized, ErrInvalidToken)
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrInvalidCSRFToken) {
+				status = http.StatusForbidden
+			}
+			WriteJSONResponseError(w, status, err)
 			return
 		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || !token.Valid {
-			WriteJSONResponseError(w, http.StatusUnauthorized, ErrInvalidToken)
+		if tokenString == "" {
+			switch {
+			// Other schemes, such as Basic credentials meant for a proxy, carry no access token
+			case !required:
+				next.ServeHTTP(w, r)
+			case r.Header.Get("Authorization") != "":
+				WriteJSONResponseError(w, http.StatusUnauthorized, ErrBearerTokenRequired)
+			default:
+				WriteJSONResponseError(w, http.StatusUnauthorized, ErrAuthorizationHeaderRequired)
+			}
 			return
 		}
 
-		userID, ok := claims["sub"].(string)
-		if !ok {
-			WriteJSONResponseError(w, http.StatusUnauthorized, ErrInvalidTokenClaims)
+		ctx, err := h.Authenticate(r.Context(), tokenString)
+		if err != nil {
+			WriteJSONResponseError(w, http.StatusUnauthorized, err)
 			return
 		}
-		ctx := context.WithValue(r.Context(), userContextKey, userID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// Authenticate verifies an access token and returns ctx populated with its user and claims,
+// for GetUserID, GetClaims and GetUser. It lets other transports, such as gRPC, authenticate
+// requests exactly like AuthMiddleware. It also records the activity of the user.
+func (h *Handler) Authenticate(ctx context.Context, tokenString string) (context.Context, error) {
+	claims, err := h.svc.AccessTokenVerify(ctx, tokenString)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	userID, ok := claims["sub"].(string)
+	if !ok {
+		return nil, ErrInvalidTokenClaims
+	}
+	h.svc.ActivityTouch(ctx, userID)
+
+	ctx = context.WithValue(ctx, userContextKey, userID)
+	ctx = context.WithValue(ctx, claimsContextKey, claims)
+	ctx = context.WithValue(ctx, userMemoContextKey, &userMemo{load: func(ctx context.Context) (*models.User, error) {
+		return h.svc.Repo.UserGetByID(ctx, userID)
+	}})
+	return ctx, nil
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/josuebrunel/ezauth/pkg/service"
 	"github.com/josuebrunel/ezauth/pkg/util"
 )
 
@@ -54,6 +56,7 @@ func (h *Handler) OAuth2Login(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} ApiResponse[service.TokenResponse]
 // @Success 302
 // @Failure 400 {object} ApiResponse[string]
+// @Failure 403 {object} ApiResponse[string]
 // @Failure 500 {object} ApiResponse[string]
 // @Router /auth/oauth2/{provider}/callback [get]
 func (h *Handler) OAuth2Callback(w http.ResponseWriter, r *http.Request) {
@@ -104,12 +107,16 @@ func (h *Handler) OAuth2Callback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := h.svc.OAuth2Authenticate(r.Context(), provider, userInfo)
+	if errors.Is(err, service.ErrFeatureDisabled) || errors.Is(err, service.ErrUserDeleted) {
+		WriteJSONResponseError(w, http.StatusForbidden, err)
+		return
+	}
 	if err != nil {
 		WriteJSONResponseError(w, http.StatusInternalServerError, fmt.Errorf("failed to authenticate user: %w", err))
 		return
 	}
 
-	tokenResp, err := h.svc.TokenCreate(r.Context(), user)
+	tokenResp, err := h.svc.TokenCreate(r.Context(), user, clientInfo(r))
 	if err != nil {
 		WriteJSONResponseError(w, http.StatusInternalServerError, fmt.Errorf("failed to create token: %w", err))
 		return
@@ -122,8 +129,13 @@ func (h *Handler) OAuth2Callback(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		q := u.Query()
-		q.Set("access_token", tokenResp.AccessToken)
-		q.Set("refresh_token", tokenResp.RefreshToken)
+		if h.cookieMode() {
+			// Keep the tokens out of the URL, the browser gets them as cookies
+			h.setTokenCookies(w, tokenResp)
+		} else {
+			q.Set("access_token", tokenResp.AccessToken)
+			q.Set("refresh_token", tokenResp.RefreshToken)
+		}
 		q.Set("expires_in", fmt.Sprintf("%d", tokenResp.ExpiresIn))
 		q.Set("token_type", tokenResp.TokenType)
 		u.RawQuery = q.Encode()
@@ -131,5 +143,5 @@ func (h *Handler) OAuth2Callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, tokenResp, nil)
+	h.writeTokenResponse(w, http.StatusOK, tokenResp)
 }
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/josuebrunel/ezauth/pkg/service"
+)
+
+// PasswordChange changes the password of the authenticated user.
+// @Summary Change password
+// @Description Change the password of the authenticated user given their current one, optionally logging out every other device. A notice is sent via email
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body service.RequestPasswordChange true "Password Change Request"
+// @Security BearerAuth
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 400 {object} ApiResponse[string]
+// @Failure 403 {object} ApiResponse[string]
+// @Failure 500 {object} ApiResponse[string]
+// @Router /auth/password/change [post]
+func (h *Handler) PasswordChange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrUserNotFoundInContext)
+		return
+	}
+
+	var req service.RequestPasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONResponseError(w, http.StatusBadRequest, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.svc.PasswordChange(r.Context(), userID, currentSessionID(r), req); err != nil {
+		switch {
+		case errors.Is(err, service.ErrPasswordTooShort), errors.Is(err, service.ErrPasswordTooLong):
+			WriteJSONResponseError(w, http.StatusBadRequest, err)
+		case errors.Is(err, service.ErrInvalidPassword), errors.Is(err, service.ErrFeatureDisabled):
+			WriteJSONResponseError(w, http.StatusForbidden, err)
+		default:
+			WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotChangePassword)
+		}
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "password changed successfully"}, nil)
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+// RequireRole returns a middleware allowing only users having role.
+// It must be used after AuthMiddleware. Other users get a 403 response.
+func (h *Handler) RequireRole(role string) func(http.Handler) http.Handler {
+	return h.RequireAnyRole(role)
+}
+
+// RequireAnyRole returns a middleware allowing only users having at least one of roles.
+// It must be used after AuthMiddleware. Other users get a 403 response.
+func (h *Handler) RequireAnyRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userRoles, err := h.requestRoles(r)
+			if err != nil {
+				WriteJSONResponseError(w, http.StatusUnauthorized, err)
+				return
+			}
+			if !userRoles.HasAny(roles...) {
+				WriteJSONResponseError(w, http.StatusForbidden, ErrInsufficientRole)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission returns a middleware allowing only users whose roles grant permission.
+// It must be used after AuthMiddleware. Other users get a 403 response.
+func (h *Handler) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			permissions, err := h.requestPermissions(r)
+			if err != nil {
+				WriteJSONResponseError(w, http.StatusUnauthorized, err)
+				return
+			}
+			if !slices.Contains(permissions, permission) {
+				WriteJSONResponseError(w, http.StatusForbidden, ErrInsufficientPermission)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestRoles returns the roles of the authenticated user.
+// They are read from the roles claim of the access token, or from the user for tokens without it.
+func (h *Handler) requestRoles(r *http.Request) (models.Roles, error) {
+	claims, err := GetClaims(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Roles != nil {
+		return claims.Roles, nil
+	}
+
+	user, err := GetUser(r.Context())
+	if err != nil {
+		return nil, ErrCouldNotRetrieveUser
+	}
+	return user.Roles, nil
+}
+
+// requestPermissions returns the permissions of the authenticated user.
+// They are read from the permissions claim of the access token, or resolved from the roles of the user for tokens without it.
+func (h *Handler) requestPermissions(r *http.Request) ([]string, error) {
+	claims, err := GetClaims(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Permissions != nil {
+		return claims.Permissions, nil
+	}
+
+	user, err := GetUser(r.Context())
+	if err != nil {
+		return nil, ErrCouldNotRetrieveUser
+	}
+	permissions, err := h.svc.UserPermissions(r.Context(), user)
+	if err != nil {
+		return nil, ErrCouldNotRetrieveUser
+	}
+	return permissions, nil
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/josuebrunel/ezauth/pkg/service"
+)
+
+// SessionList lists the active sessions of the authenticated user.
+// @Summary List sessions
+// @Description List the devices the authenticated user is logged in on. The session of the access token is flagged as current.
+// @Tags user
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} ApiResponse[[]service.Session]
+// @Failure 500 {object} ApiResponse[string]
+// @Router /auth/sessions [get]
+func (h *Handler) SessionList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrUserNotFoundInContext)
+		return
+	}
+
+	sessions, err := h.svc.SessionList(r.Context(), userID, currentSessionID(r))
+	if err != nil {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotListSessions)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, sessions, nil)
+}
+
+// SessionRevoke revokes a session of the authenticated user.
+// @Summary Revoke session
+// @Description Log the authenticated user out of a device by revoking the refresh tokens of the session
+// @Tags user
+// @Produce json
+// @Param id path string true "Session ID"
+// @Security BearerAuth
+// @Success 200 {object} ApiResponse[map[string]string]
+// @Failure 404 {object} ApiResponse[string]
+// @Failure 500 {object} ApiResponse[string]
+// @Router /auth/sessions/{id} [delete]
+func (h *Handler) SessionRevoke(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userContextKey).(string)
+	if !ok {
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrUserNotFoundInContext)
+		return
+	}
+
+	if err := h.svc.SessionRevoke(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			WriteJSONResponseError(w, http.StatusNotFound, ErrSessionNotFound)
+			return
+		}
+		WriteJSONResponseError(w, http.StatusInternalServerError, ErrCouldNotRevokeToken)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "session revoked"}, nil)
+}
+
+// clientInfo records the user agent and IP address of the request in the session.
+// The IP address is the one set by middleware.RealIP when it is in use.
+func clientInfo(r *http.Request) service.TokenOption {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return service.WithClientInfo(r.UserAgent(), ip)
+}
+
+// currentSessionID returns the session of the access token of the request.
+func currentSessionID(r *http.Request) string {
+	claims, err := GetClaims(r.Context())
+	if err != nil {
+		return ""
+	}
+	return claims.SessionID
+}
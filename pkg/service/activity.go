@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+// Default activity settings, used when the configuration leaves them unset.
+const (
+	DefaultActivityInterval      = 5 * time.Minute
+	DefaultActivityFlushInterval = 30 * time.Second
+)
+
+// activityTracker debounces writes to users.last_active_at. Activity of a user is
+// recorded at most once per activity interval, and recorded activity is written
+// in a single batch once per flush interval by ActivityFlushRun, so hot endpoints
+// do not write to the users table on every request.
+type activityTracker struct {
+	mu      sync.Mutex
+	flushMu sync.Mutex
+	seen    map[string]time.Time // user id -> last recorded activity
+	pending map[string]time.Time // user id -> activity not written yet
+}
+
+// ActivityTouch records activity of a user, typically a login, a token refresh or an authenticated request.
+// It does not write to the database: recorded activity is written to last_active_at by ActivityFlush,
+// which ActivityFlushRun calls once per flush interval.
+func (a *Auth) ActivityTouch(ctx context.Context, userID string) {
+	if userID == "" {
+		return
+	}
+
+	now := time.Now().UTC()
+	interval := ttlOrDefault(a.Cfg.Activity.Interval, DefaultActivityInterval)
+
+	t := a.activity
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if last, ok := t.seen[userID]; ok && now.Sub(last) < interval {
+		return
+	}
+	if t.seen == nil {
+		t.seen = map[string]time.Time{}
+		t.pending = map[string]time.Time{}
+	}
+	t.seen[userID] = now
+	t.pending[userID] = now
+}
+
+// ActivityFlush writes the recorded activity not written yet. Call it on shutdown
+// so the activity recorded since the last flush is not lost.
+func (a *Auth) ActivityFlush(ctx context.Context) error {
+	t := a.activity
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+
+	now := time.Now().UTC()
+	interval := ttlOrDefault(a.Cfg.Activity.Interval, DefaultActivityInterval)
+
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = map[string]time.Time{}
+	// Users not seen within the interval would be recorded on their next activity anyway
+	for userID, last := range t.seen {
+		if now.Sub(last) >= interval {
+			delete(t.seen, userID)
+		}
+	}
+	t.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if err := a.Repo.UserActivityUpdate(ctx, pending); err != nil {
+		xlog.Error("failed to write user activity", "error", err, "users", len(pending))
+		// Keep the activity for the next flush unless newer activity was recorded meanwhile
+		t.mu.Lock()
+		for userID, at := range pending {
+			if _, ok := t.pending[userID]; !ok {
+				t.pending[userID] = at
+			}
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// ActivityFlushRun runs ActivityFlush every Activity.FlushInterval, until ctx is done.
+// Run it in its own goroutine, and call ActivityFlush once more on shutdown.
+func (a *Auth) ActivityFlushRun(ctx context.Context) {
+	ticker := time.NewTicker(ttlOrDefault(a.Cfg.Activity.FlushInterval, DefaultActivityFlushInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Errors are logged by ActivityFlush, and the activity is kept for the next flush
+		_ = a.ActivityFlush(ctx)
+	}
+}
+
+// UserListInactive returns the users who have not been active since the given time,
+// including those who were never active, least recently active first.
+// Activity not flushed yet is not taken into account.
+func (a *Auth) UserListInactive(ctx context.Context, since time.Time) ([]*models.User, error) {
+	return a.Repo.UserListInactive(ctx, since.UTC())
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+func TestActivity(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+
+	lastActiveAt := func(t *testing.T, userID string) *time.Time {
+		t.Helper()
+		user, err := auth.Repo.UserGetByID(ctx, userID)
+		if err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		return user.LastActiveAt
+	}
+
+	t.Run("Login", func(t *testing.T) {
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "activity-login@example.com", Provider: "local"})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		if lastActiveAt(t, user.ID) != nil {
+			t.Fatal("expected new user to have no activity")
+		}
+
+		if _, err := auth.TokenCreate(ctx, user); err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+		if lastActiveAt(t, user.ID) != nil {
+			t.Error("expected login not to write to the database")
+		}
+		if err := auth.ActivityFlush(ctx); err != nil {
+			t.Fatalf("ActivityFlush() unexpected error: %v", err)
+		}
+		if lastActiveAt(t, user.ID) == nil {
+			t.Error("expected login to set last_active_at")
+		}
+	})
+
+	t.Run("FlushRun", func(t *testing.T) {
+		auth.Cfg.Activity.FlushInterval = 10 * time.Millisecond
+		defer func() { auth.Cfg.Activity.FlushInterval = 0 }()
+
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "activity-run@example.com", Provider: "local"})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			auth.ActivityFlushRun(runCtx)
+			close(done)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		auth.ActivityTouch(ctx, user.ID)
+		deadline := time.Now().Add(time.Second)
+		for lastActiveAt(t, user.ID) == nil {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the background flush to set last_active_at")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("Debounce", func(t *testing.T) {
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "activity-debounce@example.com", Provider: "local"})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+
+		auth.ActivityTouch(ctx, user.ID)
+		if err := auth.ActivityFlush(ctx); err != nil {
+			t.Fatalf("ActivityFlush() unexpected error: %v", err)
+		}
+		first := lastActiveAt(t, user.ID)
+		if first == nil {
+			t.Fatal("expected flush to set last_active_at")
+		}
+
+		auth.ActivityTouch(ctx, user.ID)
+		auth.activity.mu.Lock()
+		_, pending := auth.activity.pending[user.ID]
+		auth.activity.mu.Unlock()
+		if pending {
+			t.Error("expected activity within the interval not to be recorded")
+		}
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		auth.Cfg.Activity.FlushInterval = time.Hour
+		defer func() { auth.Cfg.Activity.FlushInterval = 0 }()
+
+		var users []*models.User
+		for _, email := range []string{"activity-batch1@example.com", "activity-batch2@example.com"} {
+			user, err := auth.Repo.UserCreate(ctx, &models.User{Email: email, Provider: "local"})
+			if err != nil {
+				t.Fatalf("failed to create user: %v", err)
+			}
+			users = append(users, user)
+			auth.ActivityTouch(ctx, user.ID)
+		}
+
+		for _, user := range users {
+			if lastActiveAt(t, user.ID) != nil {
+				t.Errorf("expected activity of %s not to be written before the flush interval", user.Email)
+			}
+		}
+		if err := auth.ActivityFlush(ctx); err != nil {
+			t.Fatalf("ActivityFlush() unexpected error: %v", err)
+		}
+		for _, user := range users {
+			if lastActiveAt(t, user.ID) == nil {
+				t.Errorf("expected flush to write activity of %s", user.Email)
+			}
+		}
+	})
+
+	t.Run("ListInactive", func(t *testing.T) {
+		stale := time.Now().Add(-48 * time.Hour).UTC()
+		never, err := auth.Repo.UserCreate(ctx, &models.User{Email: "activity-never@example.com", Provider: "local"})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		old, err := auth.Repo.UserCreate(ctx, &models.User{Email: "activity-old@example.com", Provider: "local", LastActiveAt: &stale})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		active, err := auth.Repo.UserCreate(ctx, &models.User{Email: "activity-active@example.com", Provider: "local"})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		if err := auth.Repo.UserActivityUpdate(ctx, map[string]time.Time{active.ID: time.Now().UTC()}); err != nil {
+			t.Fatalf("UserActivityUpdate() unexpected error: %v", err)
+		}
+
+		users, err := auth.UserListInactive(ctx, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			t.Fatalf("UserListInactive() unexpected error: %v", err)
+		}
+		found := map[string]bool{}
+		for _, user := range users {
+			found[user.ID] = true
+		}
+		if !found[never.ID] || !found[old.ID] {
+			t.Error("expected never active and stale users to be listed")
+		}
+		if found[active.ID] {
+			t.Error("expected recently active user not to be listed")
+		}
+	})
+
+	t.Run("NeverMovesBackwards", func(t *testing.T) {
+		now := time.Now().UTC()
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "activity-backwards@example.com", Provider: "local", LastActiveAt: &now})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		if err := auth.Repo.UserActivityUpdate(ctx, map[string]time.Time{user.ID: now.Add(-time.Hour)}); err != nil {
+			t.Fatalf("UserActivityUpdate() unexpected error: %v", err)
+		}
+		if got := lastActiveAt(t, user.ID); got == nil || got.Before(now.Add(-time.Minute)) {
+			t.Errorf("expected last_active_at to stay at %v, got %v", now, got)
+		}
+	})
+}
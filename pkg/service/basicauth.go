@@ -7,10 +7,12 @@ import (
 	"time"
 
 	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/gopkg/xlog"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // RequestBasicAuth defines the parameters for basic authentication (email/password).
+// It has no roles since users must not pick their own; roles are given with UserRoleAdd.
 type RequestBasicAuth struct {
 	Email     string         `json:"email"`
 	Password  string         `json:"password"`
@@ -18,7 +20,6 @@ type RequestBasicAuth struct {
 	LastName  string         `json:"last_name"`
 	Locale    string         `json:"locale"`
 	Timezone  string         `json:"timezone"`
-	Roles     string         `json:"roles"`
 	Data      map[string]any `json:"data"`
 }
 
@@ -33,8 +34,28 @@ type RequestPasswordResetConfirm struct {
 	Password string `json:"password"`
 }
 
-// UserCreate creates a new user with email and password.
+// UserCreate creates a new user with email and password and emails them a verification token.
+// It returns ErrRegistrationDisabled or ErrPasswordLoginDisabled if those features are turned off,
+// the error of PasswordValidate if the password does not meet the password policy,
+// and ErrInvalidLocale or ErrInvalidTimezone on the same rules as UserProfileUpdate.
 func (a *Auth) UserCreate(ctx context.Context, req *RequestBasicAuth) (*models.User, error) {
+	if !a.RegistrationEnabled() {
+		return nil, ErrRegistrationDisabled
+	}
+	if !a.PasswordLoginEnabled() {
+		return nil, ErrPasswordLoginDisabled
+	}
+	if err := a.PasswordValidate(req.Password); err != nil {
+		return nil, err
+	}
+	locale, err := localeCanonical(req.Locale)
+	if err != nil {
+		return nil, err
+	}
+	if err := timezoneValidate(req.Timezone); err != nil {
+		return nil, err
+	}
+
 	hash, err := a.UserHashPassword(req.Password)
 	if err != nil {
 		return nil, err
@@ -45,12 +66,20 @@ func (a *Auth) UserCreate(ctx context.Context, req *RequestBasicAuth) (*models.U
 		UserMetadata: req.Data,
 		FirstName:    req.FirstName,
 		LastName:     req.LastName,
-		Locale:       req.Locale,
+		Locale:       locale,
 		Timezone:     req.Timezone,
-		Roles:        req.Roles,
 		Provider:     "local",
 	}
-	return a.Repo.UserCreate(ctx, user)
+	user, err = a.Repo.UserCreate(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account exists even if the email could not be sent; another one can be requested
+	if err := a.EmailVerificationSend(ctx, user); err != nil {
+		xlog.Error("failed to send verification email", "error", err, "user_id", user.ID)
+	}
+	return user, nil
 }
 
 // UserHashPassword generates a bcrypt hash of the given password.
@@ -61,6 +90,10 @@ func (a Auth) UserHashPassword(password string) (string, error) {
 
 // UserAuthenticate authenticates a user with email and password.
 func (a Auth) UserAuthenticate(ctx context.Context, req RequestBasicAuth) (*models.User, error) {
+	if !a.PasswordLoginEnabled() {
+		return nil, ErrPasswordLoginDisabled
+	}
+
 	user, err := a.Repo.UserGetByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, err
@@ -69,6 +102,14 @@ func (a Auth) UserAuthenticate(ctx context.Context, req RequestBasicAuth) (*mode
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
 		return nil, errors.New("invalid credentials")
 	}
+
+	// Checked after the password so the verification status of an account is not leaked
+	if a.Cfg.Features.RequireEmailVerification && !user.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+	if user.DeletedAt != nil {
+		return nil, ErrUserDeleted
+	}
 	return user, nil
 }
 
@@ -88,9 +129,14 @@ func (a Auth) UserUpdate(ctx context.Context, user *models.User) (*models.User,
 }
 
 // PasswordResetRequest initiates the password reset flow.
-func (a *Auth) PasswordResetRequest(ctx context.Context, req RequestPasswordReset) error {
+// The token lifetime defaults to the configuration and can be overridden with WithTokenTTL.
+func (a *Auth) PasswordResetRequest(ctx context.Context, req RequestPasswordReset, opts ...TokenOption) error {
+	if !a.PasswordResetEnabled() {
+		return ErrPasswordResetDisabled
+	}
+
 	user, err := a.Repo.UserGetByEmail(ctx, req.Email)
-	if err != nil {
+	if err != nil || user.DeletedAt != nil {
 		// We don't want to leak if a user exists or not
 		return nil
 	}
@@ -100,12 +146,17 @@ func (a *Auth) PasswordResetRequest(ctx context.Context, req RequestPasswordRese
 		return err
 	}
 
+	o := tokenOptions{ttl: ttlOrDefault(a.Cfg.Token.PasswordResetTTL, DefaultPasswordResetTTL)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	token := &models.Token{
 		UserID:    user.ID,
 		Token:     tokenValue,
 		TokenType: models.TokenTypePasswordReset,
-		ExpiresAt: time.Now().Add(1 * time.Hour),
-		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().UTC().Add(o.ttl),
+		CreatedAt: time.Now().UTC(),
 		Revoked:   false,
 		Metadata:  models.JSONMap{},
 	}
@@ -122,6 +173,13 @@ func (a *Auth) PasswordResetRequest(ctx context.Context, req RequestPasswordRese
 
 // PasswordResetConfirm completes the password reset flow.
 func (a *Auth) PasswordResetConfirm(ctx context.Context, req RequestPasswordResetConfirm) error {
+	if !a.PasswordResetEnabled() {
+		return ErrPasswordResetDisabled
+	}
+	if err := a.PasswordValidate(req.Password); err != nil {
+		return err
+	}
+
 	token, err := a.Repo.TokenGetByToken(ctx, req.Token)
 	if err != nil {
 		return errors.New("invalid or expired token")
@@ -144,11 +202,11 @@ func (a *Auth) PasswordResetConfirm(ctx context.Context, req RequestPasswordRese
 		return err
 	}
 
-	// Update password
-	if _, err := a.UserUpdatePassword(ctx, user, req.Password); err != nil {
+	if err := a.actionTokenUse(ctx, token); err != nil {
 		return err
 	}
 
-	// Revoke token
-	return a.Repo.TokenRevoke(ctx, token.ID)
+	// Update password
+	_, err = a.UserUpdatePassword(ctx, user, req.Password)
+	return err
 }
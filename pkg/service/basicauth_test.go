@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/josuebrunel/ezauth/pkg/config"
@@ -53,7 +54,6 @@ func TestBasicAuthOperations(t *testing.T) {
 			LastName:  "Doe",
 			Locale:    "en-US",
 			Timezone:  "UTC",
-			Roles:     "admin,user",
 			Data:      map[string]any{"role": "admin"},
 		}
 
@@ -79,12 +79,41 @@ func TestBasicAuthOperations(t *testing.T) {
 		if user.Timezone != "UTC" {
 			t.Errorf("expected Timezone UTC, got %s", user.Timezone)
 		}
-		if user.Roles != "admin,user" {
-			t.Errorf("expected Roles admin,user, got %s", user.Roles)
+		if len(user.Roles) != 0 {
+			t.Errorf("expected no roles, got %s", user.Roles)
 		}
 		createdUser = user
 	})
 
+	t.Run("UserCreate_Profile", func(t *testing.T) {
+		user, err := auth.UserCreate(ctx, &RequestBasicAuth{Email: "profile-create@example.com", Password: password, Locale: "fr_ca"})
+		if err != nil {
+			t.Fatalf("UserCreate failed: %v", err)
+		}
+		if user.Locale != "fr-CA" {
+			t.Errorf("expected canonical Locale fr-CA, got %s", user.Locale)
+		}
+
+		tests := []struct {
+			name string
+			req  *RequestBasicAuth
+			err  error
+		}{
+			{name: "Locale", req: &RequestBasicAuth{Email: "invalid-locale@example.com", Password: password, Locale: "not a locale"}, err: ErrInvalidLocale},
+			{name: "Timezone", req: &RequestBasicAuth{Email: "invalid-timezone@example.com", Password: password, Timezone: "Local"}, err: ErrInvalidTimezone},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := auth.UserCreate(ctx, tt.req); !errors.Is(err, tt.err) {
+					t.Errorf("expected %v, got %v", tt.err, err)
+				}
+				if _, err := auth.Repo.UserGetByEmail(ctx, tt.req.Email); err == nil {
+					t.Error("expected the user not to be created")
+				}
+			})
+		}
+	})
+
 	t.Run("UserAuthenticate_Success", func(t *testing.T) {
 		req := RequestBasicAuth{
 			Email:    email,
@@ -145,7 +174,7 @@ func TestBasicAuthOperations(t *testing.T) {
 		createdUser.LastName = "Smith"
 		createdUser.Locale = "fr-FR"
 		createdUser.Timezone = "Europe/Paris"
-		createdUser.Roles = "superadmin"
+		createdUser.Roles = models.Roles{"superadmin"}
 
 		updatedUser, err := auth.UserUpdate(ctx, createdUser)
 		if err != nil {
@@ -174,7 +203,7 @@ func TestBasicAuthOperations(t *testing.T) {
 		if fetchedUser.Timezone != "Europe/Paris" {
 			t.Errorf("expected Timezone Europe/Paris, got %s", fetchedUser.Timezone)
 		}
-		if fetchedUser.Roles != "superadmin" {
+		if fetchedUser.Roles.String() != "superadmin" {
 			t.Errorf("expected Roles superadmin, got %s", fetchedUser.Roles)
 		}
 		// Update reference
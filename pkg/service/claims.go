@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/ezauth/pkg/util"
+)
+
+// ClaimsFunc returns extra claims to add to the access token issued for user.
+// Registered claims (iss, sub, aud, exp, nbf, iat, jti) and sid cannot be overridden.
+type ClaimsFunc func(ctx context.Context, user *models.User) (map[string]any, error)
+
+// Claims are the verified claims of an access token.
+type Claims struct {
+	Subject     string         `json:"sub"`
+	Email       string         `json:"email"`
+	Roles       models.Roles   `json:"roles"`
+	Permissions []string       `json:"permissions"`
+	SessionID   string         `json:"sid,omitempty"`
+	ID          string         `json:"jti"`
+	Issuer      string         `json:"iss,omitempty"`
+	Audience    []string       `json:"aud,omitempty"`
+	IssuedAt    time.Time      `json:"iat"`
+	ExpiresAt   time.Time      `json:"exp"`
+	Custom      map[string]any `json:"custom,omitempty"` // other claims, such as app_metadata and the ones added with OnClaims
+}
+
+// NewClaims maps the claims returned by AccessTokenParse or AccessTokenVerify to Claims.
+// Roles and Permissions are nil when the token has no such claim.
+func NewClaims(claims jwt.MapClaims) *Claims {
+	c := &Claims{Custom: map[string]any{}}
+	c.Subject, _ = claims.GetSubject()
+	c.Issuer, _ = claims.GetIssuer()
+	c.Audience, _ = claims.GetAudience()
+	c.Email, _ = claims["email"].(string)
+	c.SessionID, _ = claims["sid"].(string)
+	c.ID, _ = claims["jti"].(string)
+	if iat, _ := claims.GetIssuedAt(); iat != nil {
+		c.IssuedAt = iat.Time
+	}
+	if exp, _ := claims.GetExpirationTime(); exp != nil {
+		c.ExpiresAt = exp.Time
+	}
+	if roles, ok := claimStrings(claims["roles"]); ok {
+		c.Roles = models.Roles(roles)
+	}
+	c.Permissions, _ = claimStrings(claims["permissions"])
+
+	for k, v := range claims {
+		if !registeredClaims[k] && !serviceClaims[k] {
+			c.Custom[k] = v
+		}
+	}
+	return c
+}
+
+// HasPermission reports whether the claims grant permission.
+func (c *Claims) HasPermission(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
+
+// registeredClaims are set by the service and never taken from a ClaimsFunc.
+var registeredClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true, "sid": true,
+}
+
+// serviceClaims are the other claims set by the service, mapped to fields of Claims.
+var serviceClaims = map[string]bool{"email": true, "roles": true, "permissions": true}
+
+// OnClaims registers a function adding custom claims to every access token.
+// It must be called before the service starts handling requests.
+func (a *Auth) OnClaims(fn ClaimsFunc) {
+	a.claimsFuncs = append(a.claimsFuncs, fn)
+}
+
+func (a *Auth) accessTokenClaims(ctx context.Context, user *models.User, exp time.Time, sessionID string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	for _, fn := range a.claimsFuncs {
+		extra, err := fn(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range extra {
+			if !registeredClaims[k] {
+				claims[k] = v
+			}
+		}
+	}
+
+	claims["sub"] = user.ID
+	claims["email"] = user.Email
+	claims["roles"] = append([]string{}, user.Roles...)
+	permissions, err := a.UserPermissions(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	claims["permissions"] = permissions
+	claims["jti"] = util.RandomString(32)
+	claims["exp"] = jwt.NewNumericDate(exp)
+	claims["iat"] = jwt.NewNumericDate(time.Now())
+	if sessionID != "" {
+		claims["sid"] = sessionID
+	}
+
+	if iss := a.Cfg.JWT.Issuer; iss != "" {
+		claims["iss"] = iss
+	}
+	if aud := a.Cfg.JWT.Audience; len(aud) > 0 {
+		claims["aud"] = jwt.ClaimStrings(aud)
+	}
+
+	if len(a.Cfg.JWT.AppMetadataClaims) > 0 {
+		appMetadata := map[string]any{}
+		for _, key := range a.Cfg.JWT.AppMetadataClaims {
+			if v, ok := user.AppMetadata[key]; ok {
+				appMetadata[key] = v
+			}
+		}
+		if len(appMetadata) > 0 {
+			claims["app_metadata"] = appMetadata
+		}
+	}
+
+	return claims, nil
+}
+
+// accessTokenParserOptions returns the claim validations enabled by the configuration.
+func (a *Auth) accessTokenParserOptions() []jwt.ParserOption {
+	var opts []jwt.ParserOption
+	if iss := a.Cfg.JWT.Issuer; iss != "" {
+		opts = append(opts, jwt.WithIssuer(iss))
+	}
+	if aud := a.Cfg.JWT.Audience; len(aud) > 0 {
+		opts = append(opts, jwt.WithAudience(aud...))
+	}
+	return opts
+}
+
+// claimStrings returns the value of a claim holding a list of strings.
+func claimStrings(v any) ([]string, bool) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/config"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+func TestAccessTokenClaims(t *testing.T) {
+	auth := setupTestDB(t)
+	cfg, keys := auth.Cfg, auth.Keys
+	cfg.JWT = config.JWT{
+		Issuer:            "https://auth.example.com",
+		Audience:          []string{"api", "admin"},
+		AppMetadataClaims: []string{"plan", "tenant"},
+	}
+	auth.OnClaims(func(ctx context.Context, user *models.User) (map[string]any, error) {
+		return map[string]any{"org": "acme", "sub": "spoofed"}, nil
+	})
+
+	user := &models.User{
+		ID:          "user-123",
+		Email:       "claims@example.com",
+		Roles:       models.Roles{"user", "admin"},
+		AppMetadata: models.JSONMap{"plan": "pro", "internal": "secret"},
+	}
+	tokenString, _, err := auth.generateAccessToken(context.Background(), user, time.Hour, "")
+	if err != nil {
+		t.Fatalf("generateAccessToken() unexpected error: %v", err)
+	}
+
+	t.Run("Claims", func(t *testing.T) {
+		claims, err := auth.AccessTokenParse(tokenString)
+		if err != nil {
+			t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+		}
+		if claims["sub"] != user.ID {
+			t.Errorf("expected sub %s, got %v", user.ID, claims["sub"])
+		}
+		if claims["iss"] != cfg.JWT.Issuer {
+			t.Errorf("expected iss %s, got %v", cfg.JWT.Issuer, claims["iss"])
+		}
+		if aud, _ := claims.GetAudience(); !reflect.DeepEqual([]string(aud), cfg.JWT.Audience) {
+			t.Errorf("expected aud %v, got %v", cfg.JWT.Audience, aud)
+		}
+		if jti, _ := claims["jti"].(string); jti == "" {
+			t.Error("expected a jti")
+		}
+		if !reflect.DeepEqual(claims["roles"], []any{"user", "admin"}) {
+			t.Errorf("expected roles [user admin], got %v", claims["roles"])
+		}
+		if !reflect.DeepEqual(claims["app_metadata"], map[string]any{"plan": "pro"}) {
+			t.Errorf("expected only allowlisted app_metadata, got %v", claims["app_metadata"])
+		}
+		if claims["org"] != "acme" {
+			t.Errorf("expected custom claim org, got %v", claims["org"])
+		}
+	})
+
+	t.Run("NewClaims", func(t *testing.T) {
+		parsed, err := auth.AccessTokenParse(tokenString)
+		if err != nil {
+			t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+		}
+		claims := NewClaims(parsed)
+		if claims.Subject != user.ID || claims.Email != user.Email || claims.Issuer != cfg.JWT.Issuer {
+			t.Errorf("unexpected claims: %+v", claims)
+		}
+		if !reflect.DeepEqual(claims.Roles, user.Roles) || claims.Permissions == nil {
+			t.Errorf("expected roles %v and empty permissions, got %v and %v", user.Roles, claims.Roles, claims.Permissions)
+		}
+		if claims.ExpiresAt.Before(time.Now()) || claims.IssuedAt.IsZero() {
+			t.Errorf("unexpected times: iat %v, exp %v", claims.IssuedAt, claims.ExpiresAt)
+		}
+		if _, ok := claims.Custom["app_metadata"]; !ok || claims.Custom["org"] != "acme" {
+			t.Errorf("expected app_metadata and org in custom claims, got %v", claims.Custom)
+		}
+		if _, ok := claims.Custom["sub"]; ok {
+			t.Error("expected registered claims to be left out of custom claims")
+		}
+	})
+
+	t.Run("UniqueJTI", func(t *testing.T) {
+		other, _, err := auth.generateAccessToken(context.Background(), user, time.Hour, "")
+		if err != nil {
+			t.Fatalf("generateAccessToken() unexpected error: %v", err)
+		}
+		a, _ := auth.AccessTokenParse(tokenString)
+		b, _ := auth.AccessTokenParse(other)
+		if a["jti"] == b["jti"] {
+			t.Error("expected each token to have a unique jti")
+		}
+	})
+
+	t.Run("WrongIssuer", func(t *testing.T) {
+		other := &Auth{Cfg: &config.Config{JWTSecret: "test-secret", JWT: config.JWT{Issuer: "https://other.example.com"}}, Keys: keys}
+		if _, err := other.AccessTokenParse(tokenString); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+			t.Errorf("expected ErrTokenInvalidIssuer, got %v", err)
+		}
+	})
+
+	t.Run("WrongAudience", func(t *testing.T) {
+		other := &Auth{Cfg: &config.Config{JWTSecret: "test-secret", JWT: config.JWT{Audience: []string{"billing"}}}, Keys: keys}
+		if _, err := other.AccessTokenParse(tokenString); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+			t.Errorf("expected ErrTokenInvalidAudience, got %v", err)
+		}
+	})
+
+	t.Run("ClaimsFuncError", func(t *testing.T) {
+		failing := &Auth{Cfg: cfg, Keys: keys, Repo: auth.Repo}
+		failing.OnClaims(func(ctx context.Context, user *models.User) (map[string]any, error) {
+			return nil, errors.New("boom")
+		})
+		if _, _, err := failing.generateAccessToken(context.Background(), user, time.Hour, ""); err == nil {
+			t.Error("expected error from failing ClaimsFunc")
+		}
+	})
+}
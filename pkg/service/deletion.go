@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+// Default deletion settings, used when the configuration leaves them unset.
+const (
+	DefaultDeletionGracePeriod   = 30 * 24 * time.Hour
+	DefaultDeletionPurgeInterval = time.Hour
+)
+
+var (
+	ErrUserDeleted        = errors.New("account pending deletion")
+	ErrUserNotDeleted     = errors.New("account not pending deletion")
+	ErrRestoreWindowEnded = errors.New("account can no longer be restored")
+)
+
+// UserDelete schedules the deletion of a user's account. The account is marked as pending deletion,
+// the user is logged out of every device, and a restore link valid for the grace period is emailed to them.
+// UserPurge deletes the account once the grace period is over.
+func (a *Auth) UserDelete(ctx context.Context, userID string) error {
+	user, err := a.Repo.UserGetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.DeletedAt != nil {
+		return ErrUserDeleted
+	}
+
+	now := time.Now().UTC()
+	if err := a.Repo.UserSoftDelete(ctx, user.ID, now); err != nil {
+		return err
+	}
+
+	if err := a.SessionRevokeAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	// Pending links would otherwise act on an account the user asked to delete
+	for _, tokenType := range []string{
+		models.TokenTypePasswordReset,
+		models.TokenTypeEmailVerification,
+		models.TokenTypeEmailChange,
+		models.TokenTypeEmailRevert,
+	} {
+		if _, err := a.Repo.TokenRevokeByUser(ctx, user.ID, tokenType); err != nil {
+			return err
+		}
+	}
+
+	gracePeriod := ttlOrDefault(a.Cfg.Deletion.GracePeriod, DefaultDeletionGracePeriod)
+	restore, err := a.actionTokenCreate(ctx, user.ID, models.TokenTypeAccountRestore, gracePeriod, models.JSONMap{})
+	if err != nil {
+		return err
+	}
+
+	xlog.Info("user deleted", "user_id", user.ID, "purge_after", now.Add(gracePeriod))
+
+	// The account is deleted even if the email could not be sent
+	subject := "Your account will be deleted"
+	body := fmt.Sprintf("Your account will be permanently deleted on %s. To keep it, click the following link: %s",
+		now.Add(gracePeriod).Format(time.RFC1123), a.link("/user/restore", restore))
+	if err := a.Mailer.Send(user.Email, subject, body); err != nil {
+		xlog.Error("failed to send account deletion email", "error", err, "user_id", user.ID)
+	}
+	return nil
+}
+
+// UserRestore cancels the deletion of the account the restore link was sent for.
+// The user has to log in again, since their sessions were revoked on deletion.
+func (a *Auth) UserRestore(ctx context.Context, tokenValue string) (*models.User, error) {
+	token, err := a.actionTokenGet(ctx, tokenValue, models.TokenTypeAccountRestore)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.Repo.UserGetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt == nil {
+		return nil, ErrUserNotDeleted
+	}
+
+	gracePeriod := ttlOrDefault(a.Cfg.Deletion.GracePeriod, DefaultDeletionGracePeriod)
+	if time.Since(*user.DeletedAt) >= gracePeriod {
+		return nil, ErrRestoreWindowEnded
+	}
+
+	if err := a.actionTokenUse(ctx, token); err != nil {
+		return nil, err
+	}
+
+	if err := a.Repo.UserRestore(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	user.DeletedAt = nil
+
+	xlog.Info("user restored", "user_id", user.ID)
+	return user, nil
+}
+
+// UserPurge permanently deletes the accounts pending deletion for longer than the grace period,
+// along with their tokens. It returns the number of accounts deleted; accounts that could not be
+// deleted are logged and retried by the next purge.
+func (a *Auth) UserPurge(ctx context.Context) (int, error) {
+	gracePeriod := ttlOrDefault(a.Cfg.Deletion.GracePeriod, DefaultDeletionGracePeriod)
+	users, err := a.Repo.UserListDeleted(ctx, time.Now().UTC().Add(-gracePeriod))
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	var errs []error
+	for _, user := range users {
+		if err := a.Repo.UserDelete(ctx, user.ID); err != nil {
+			xlog.Error("failed to purge user", "error", err, "user_id", user.ID)
+			errs = append(errs, err)
+			continue
+		}
+		purged++
+	}
+
+	if purged > 0 {
+		xlog.Info("deleted users purged", "users", purged)
+	}
+	return purged, errors.Join(errs...)
+}
+
+// UserPurgeRun runs UserPurge right away and then every Deletion.PurgeInterval, until ctx is done.
+// Run it in its own goroutine; running it on several instances is safe.
+func (a *Auth) UserPurgeRun(ctx context.Context) {
+	ticker := time.NewTicker(ttlOrDefault(a.Cfg.Deletion.PurgeInterval, DefaultDeletionPurgeInterval))
+	defer ticker.Stop()
+
+	for {
+		if _, err := a.UserPurge(ctx); err != nil {
+			xlog.Error("failed to purge deleted users", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserDeletion(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+	mockMailer := auth.Mailer.(*MockMailer)
+
+	// newUser creates a password user logged in on a session, returning the session tokens
+	newUser := func(t *testing.T, email string) (*models.User, *TokenResponse) {
+		t.Helper()
+		hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: email, Provider: "local", PasswordHash: string(hash)})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		tokens, err := auth.TokenCreate(ctx, user)
+		if err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+		return user, tokens
+	}
+
+	// restoreToken extracts the token from the last email sent - "...?token=<token>"
+	restoreToken := func(t *testing.T, email string) string {
+		t.Helper()
+		sent := mockMailer.SentEmails[len(mockMailer.SentEmails)-1]
+		if sent["to"] != email || !strings.Contains(sent["body"], "/auth/user/restore?token=") {
+			t.Fatalf("expected a restore link to be sent to %s, got %v", email, sent)
+		}
+		return sent["body"][len(sent["body"])-64:] // It's a 32-byte hex string = 64 chars
+	}
+
+	t.Run("DeleteAndRestore", func(t *testing.T) {
+		user, tokens := newUser(t, "delete-restore@example.com")
+
+		if err := auth.UserDelete(ctx, user.ID); err != nil {
+			t.Fatalf("UserDelete() unexpected error: %v", err)
+		}
+		if err := auth.UserDelete(ctx, user.ID); !errors.Is(err, ErrUserDeleted) {
+			t.Errorf("expected ErrUserDeleted when deleting twice, got %v", err)
+		}
+		token := restoreToken(t, user.Email)
+
+		// The account is kept but can no longer be used
+		deleted, err := auth.Repo.UserGetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("expected the user to be kept during the grace period: %v", err)
+		}
+		if deleted.DeletedAt == nil {
+			t.Fatal("expected the user to be pending deletion")
+		}
+		if _, err := auth.UserAuthenticate(ctx, RequestBasicAuth{Email: user.Email, Password: "password123"}); !errors.Is(err, ErrUserDeleted) {
+			t.Errorf("expected ErrUserDeleted on login, got %v", err)
+		}
+		if _, err := auth.TokenRefresh(ctx, tokens.RefreshToken); err == nil {
+			t.Error("expected the session to be revoked")
+		}
+		if _, err := auth.TokenCreate(ctx, deleted); !errors.Is(err, ErrUserDeleted) {
+			t.Errorf("expected ErrUserDeleted on token creation, got %v", err)
+		}
+		if _, err := auth.OAuth2Authenticate(ctx, "github", &OAuth2UserInfo{ID: "delete-restore", Email: user.Email}); !errors.Is(err, ErrUserDeleted) {
+			t.Errorf("expected ErrUserDeleted on OAuth2 login, got %v", err)
+		}
+
+		restored, err := auth.UserRestore(ctx, token)
+		if err != nil {
+			t.Fatalf("UserRestore() unexpected error: %v", err)
+		}
+		if restored.DeletedAt != nil {
+			t.Error("expected the user to be restored")
+		}
+		if _, err := auth.UserAuthenticate(ctx, RequestBasicAuth{Email: user.Email, Password: "password123"}); err != nil {
+			t.Errorf("expected login to work after restore, got %v", err)
+		}
+		if _, err := auth.UserRestore(ctx, token); err == nil {
+			t.Error("expected the restore token to be single use")
+		}
+	})
+
+	t.Run("RestoreWindowEnded", func(t *testing.T) {
+		user, _ := newUser(t, "delete-expired@example.com")
+		if err := auth.UserDelete(ctx, user.ID); err != nil {
+			t.Fatalf("UserDelete() unexpected error: %v", err)
+		}
+		token := restoreToken(t, user.Email)
+
+		// Backdate the deletion past the grace period, soft deleting again keeps the deletion time
+		if err := auth.Repo.UserRestore(ctx, user.ID); err != nil {
+			t.Fatalf("UserRestore() unexpected error: %v", err)
+		}
+		if err := auth.Repo.UserSoftDelete(ctx, user.ID, time.Now().UTC().Add(-31*24*time.Hour)); err != nil {
+			t.Fatalf("UserSoftDelete() unexpected error: %v", err)
+		}
+		if _, err := auth.UserRestore(ctx, token); !errors.Is(err, ErrRestoreWindowEnded) {
+			t.Errorf("expected ErrRestoreWindowEnded, got %v", err)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		auth.Cfg.Deletion.GracePeriod = time.Second
+		defer func() { auth.Cfg.Deletion.GracePeriod = 0 }()
+
+		kept, _ := newUser(t, "purge-kept@example.com")
+		user, _ := newUser(t, "purge@example.com")
+		if err := auth.PasswordlessRequest(ctx, RequestPasswordless{Email: user.Email}); err != nil {
+			t.Fatalf("PasswordlessRequest() unexpected error: %v", err)
+		}
+		magicLink := mockMailer.SentEmails[len(mockMailer.SentEmails)-1]["body"]
+		if err := auth.Repo.UserSoftDelete(ctx, user.ID, time.Now().UTC().Add(-time.Minute)); err != nil {
+			t.Fatalf("UserSoftDelete() unexpected error: %v", err)
+		}
+
+		if _, err := auth.UserPurge(ctx); err != nil {
+			t.Fatalf("UserPurge() unexpected error: %v", err)
+		}
+
+		if _, err := auth.Repo.UserGetByID(ctx, user.ID); err == nil {
+			t.Error("expected the user to be purged")
+		}
+		if _, err := auth.Repo.UserGetByID(ctx, kept.ID); err != nil {
+			t.Errorf("expected other users to be kept, got %v", err)
+		}
+		tokens, err := auth.Repo.TokenListActive(ctx, user.ID, models.TokenTypeRefresh)
+		if err != nil {
+			t.Fatalf("TokenListActive() unexpected error: %v", err)
+		}
+		if len(tokens) != 0 {
+			t.Errorf("expected the tokens of the user to be purged, got %d", len(tokens))
+		}
+		if _, err := auth.Repo.PasswordlessTokenGetByToken(ctx, magicLink[len(magicLink)-64:]); err == nil {
+			t.Error("expected the magic links of the user to be purged")
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+// Default email change settings, used when the configuration leaves them unset.
+const (
+	DefaultEmailChangeTTL = 24 * time.Hour
+	DefaultEmailRevertTTL = 7 * 24 * time.Hour
+	DefaultRecentLoginTTL = 5 * time.Minute
+)
+
+// Metadata keys of email change and revert tokens.
+const (
+	metadataNewEmail  = "new_email"
+	metadataOldEmail  = "old_email"
+	metadataSessionID = "session_id"
+)
+
+var (
+	ErrReauthenticationRequired = errors.New("current password or a recent login required")
+	ErrInvalidEmail             = errors.New("invalid email")
+	ErrEmailUnchanged           = errors.New("email unchanged")
+	ErrEmailTaken               = errors.New("email already in use")
+)
+
+// RequestEmailChange defines the parameters for changing the email of a user.
+// Password may be left empty within the recent login window of the session.
+type RequestEmailChange struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// RequestEmailChangeToken defines the parameters for confirming or reverting an email change.
+type RequestEmailChangeToken struct {
+	Token string `json:"token"`
+}
+
+// EmailChangeRequest starts changing the email of a user. A confirmation link is sent to the new
+// address and a notice with a revert link to the current one; the email is only changed once confirmed.
+// If the new address already belongs to an account, its owner is notified instead of sent a confirmation
+// link, and no error is returned so the request cannot be used to find out which addresses are registered.
+// The user must give their current password, or have logged in to session sid within Token.RecentLoginTTL.
+// The confirmation lifetime defaults to the configuration and can be overridden with WithTokenTTL.
+func (a *Auth) EmailChangeRequest(ctx context.Context, userID, sid string, req RequestEmailChange, opts ...TokenOption) error {
+	user, err := a.Repo.UserGetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := a.reauthenticate(ctx, user, sid, req.Password); err != nil {
+		return err
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return ErrInvalidEmail
+	}
+	if strings.EqualFold(email, user.Email) {
+		return ErrEmailUnchanged
+	}
+	_, err = a.Repo.UserGetByEmail(ctx, email)
+	taken := err == nil
+
+	o := tokenOptions{ttl: ttlOrDefault(a.Cfg.Token.EmailChangeTTL, DefaultEmailChangeTTL)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Only the latest request can be confirmed
+	if _, err := a.Repo.TokenRevokeByUser(ctx, user.ID, models.TokenTypeEmailChange); err != nil {
+		return err
+	}
+
+	revert, err := a.actionTokenCreate(ctx, user.ID, models.TokenTypeEmailRevert,
+		ttlOrDefault(a.Cfg.Token.EmailRevertTTL, DefaultEmailRevertTTL), models.JSONMap{
+			metadataNewEmail: email,
+			metadataOldEmail: user.Email,
+		})
+	if err != nil {
+		return err
+	}
+
+	// Send emails
+	if taken {
+		xlog.Info("email change requested to an address in use", "user_id", user.ID)
+		subject := "Someone tried to use your email address"
+		body := "A request was made to change the email address of another account to this one. " +
+			"Your account was not changed, and you can ignore this email."
+		if err := a.Mailer.Send(email, subject, body); err != nil {
+			return err
+		}
+	} else {
+		confirm, err := a.actionTokenCreate(ctx, user.ID, models.TokenTypeEmailChange, o.ttl, models.JSONMap{
+			metadataNewEmail:  email,
+			metadataOldEmail:  user.Email,
+			metadataSessionID: sid,
+		})
+		if err != nil {
+			return err
+		}
+
+		subject := "Confirm your new email address"
+		body := fmt.Sprintf("Click the following link to confirm your new email address: %s", a.link("/email/change/confirm", confirm))
+		if err := a.Mailer.Send(email, subject, body); err != nil {
+			return err
+		}
+	}
+
+	subject := "Your email address is being changed"
+	body := fmt.Sprintf("A request was made to change the email address of your account to %s. "+
+		"If you did not make it, click the following link to keep this address and log out of every device: %s",
+		email, a.link("/email/change/revert", revert))
+	return a.Mailer.Send(user.Email, subject, body)
+}
+
+// EmailChangeConfirm changes the email of the user to the address the token was sent to.
+// The new address is marked as verified, and every session other than the one that requested the change is revoked.
+// Access tokens issued so far are revoked as well; the requesting session gets new ones on its next refresh.
+func (a *Auth) EmailChangeConfirm(ctx context.Context, tokenValue string) (*models.User, error) {
+	token, err := a.actionTokenGet(ctx, tokenValue, models.TokenTypeEmailChange)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.Repo.UserGetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	email, _ := token.Metadata[metadataNewEmail].(string)
+	if other, err := a.Repo.UserGetByEmail(ctx, email); err == nil && other.ID != user.ID {
+		return nil, ErrEmailTaken
+	}
+
+	if err := a.actionTokenUse(ctx, token); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	user.Email = email
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	user, err = a.Repo.UserUpdate(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	sid, _ := token.Metadata[metadataSessionID].(string)
+	if err := a.SessionRevokeOthers(ctx, user.ID, sid); err != nil {
+		return nil, err
+	}
+	if err := a.AccessTokenRevokeAll(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	xlog.Info("email changed", "user_id", user.ID)
+	return user, nil
+}
+
+// EmailChangeRevert undoes an email change using the link sent to the previous address.
+// A change not confirmed yet is cancelled, a confirmed one is rolled back, and the user
+// is logged out of every device since the change may have come from someone else.
+func (a *Auth) EmailChangeRevert(ctx context.Context, tokenValue string) (*models.User, error) {
+	token, err := a.actionTokenGet(ctx, tokenValue, models.TokenTypeEmailRevert)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.Repo.UserGetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.actionTokenUse(ctx, token); err != nil {
+		return nil, err
+	}
+
+	// Cancel the pending change, if any
+	if _, err := a.Repo.TokenRevokeByUser(ctx, user.ID, models.TokenTypeEmailChange); err != nil {
+		return nil, err
+	}
+
+	email, _ := token.Metadata[metadataOldEmail].(string)
+	if email != "" && user.Email != email {
+		if other, err := a.Repo.UserGetByEmail(ctx, email); err == nil && other.ID != user.ID {
+			return nil, ErrEmailTaken
+		}
+
+		// The revert link reached this address, so it is still the user's
+		now := time.Now().UTC()
+		user.Email = email
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+		user, err = a.Repo.UserUpdate(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := a.SessionRevokeAll(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	xlog.Info("email change reverted", "user_id", user.ID)
+	return user, nil
+}
+
+// reauthenticate checks that the user proved their identity just now, with their current password,
+// or by having logged in to session sid within the recent login window.
+func (a *Auth) reauthenticate(ctx context.Context, user *models.User, sid, password string) error {
+	if password != "" {
+		return passwordCheck(user, password)
+	}
+
+	if sid == "" {
+		return ErrReauthenticationRequired
+	}
+
+	tokens, err := a.Repo.TokenListActive(ctx, user.ID, models.TokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+
+	window := ttlOrDefault(a.Cfg.Token.RecentLoginTTL, DefaultRecentLoginTTL)
+	for _, token := range tokens {
+		if sessionID(token) == sid && time.Since(sessionCreatedAt(token)) < window {
+			return nil
+		}
+	}
+	return ErrReauthenticationRequired
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestEmailChange(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+	mockMailer := auth.Mailer.(*MockMailer)
+
+	// tokenSentTo extracts the token from the last email sent to an address - "...?token=<token>"
+	tokenSentTo := func(t *testing.T, email string) string {
+		t.Helper()
+		for i := len(mockMailer.SentEmails) - 1; i >= 0; i-- {
+			sent := mockMailer.SentEmails[i]
+			if sent["to"] == email {
+				body := sent["body"]
+				return body[len(body)-64:] // It's a 32-byte hex string = 64 chars
+			}
+		}
+		t.Fatalf("expected an email to be sent to %s", email)
+		return ""
+	}
+
+	// newUser creates a password user logged in on a session, returning the session tokens
+	newUser := func(t *testing.T, email string) (*models.User, *TokenResponse) {
+		t.Helper()
+		hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: email, Provider: "local", PasswordHash: string(hash)})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		tokens, err := auth.TokenCreate(ctx, user)
+		if err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+		return user, tokens
+	}
+
+	sidOf := func(t *testing.T, tokens *TokenResponse) string {
+		t.Helper()
+		claims, err := auth.AccessTokenParse(tokens.AccessToken)
+		if err != nil {
+			t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+		}
+		sid, _ := claims["sid"].(string)
+		return sid
+	}
+
+	t.Run("Confirm", func(t *testing.T) {
+		user, current := newUser(t, "change-old@example.com")
+		otherSession, err := auth.TokenCreate(ctx, user)
+		if err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+
+		newEmail := "change-new@example.com"
+		req := RequestEmailChange{Email: newEmail, Password: "password123"}
+		if err := auth.EmailChangeRequest(ctx, user.ID, sidOf(t, current), req); err != nil {
+			t.Fatalf("EmailChangeRequest() unexpected error: %v", err)
+		}
+
+		// Nothing changes before the confirmation
+		unchanged, err := auth.Repo.UserGetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if unchanged.Email != user.Email {
+			t.Fatalf("expected email to stay %s, got %s", user.Email, unchanged.Email)
+		}
+		notice := mockMailer.SentEmails[len(mockMailer.SentEmails)-1]
+		if notice["to"] != user.Email || !strings.Contains(notice["body"], "/auth/email/change/revert?token=") {
+			t.Errorf("expected a revert link to be sent to the old address, got %v", notice)
+		}
+
+		changed, err := auth.EmailChangeConfirm(ctx, tokenSentTo(t, newEmail))
+		if err != nil {
+			t.Fatalf("EmailChangeConfirm() unexpected error: %v", err)
+		}
+		if changed.Email != newEmail || !changed.EmailVerified {
+			t.Errorf("expected verified email %s, got %+v", newEmail, changed)
+		}
+
+		// Other sessions are revoked, the requesting one is kept
+		if _, err := auth.TokenRefresh(ctx, otherSession.RefreshToken); err == nil {
+			t.Error("expected other session to be revoked")
+		}
+		if _, err := auth.TokenRefresh(ctx, current.RefreshToken); err != nil {
+			t.Errorf("expected requesting session to be kept, got %v", err)
+		}
+
+		if _, err := auth.EmailChangeConfirm(ctx, tokenSentTo(t, newEmail)); err == nil {
+			t.Error("expected a used token to be rejected")
+		}
+	})
+
+	t.Run("Revert", func(t *testing.T) {
+		user, current := newUser(t, "revert-old@example.com")
+		newEmail := "revert-new@example.com"
+		req := RequestEmailChange{Email: newEmail, Password: "password123"}
+		if err := auth.EmailChangeRequest(ctx, user.ID, sidOf(t, current), req); err != nil {
+			t.Fatalf("EmailChangeRequest() unexpected error: %v", err)
+		}
+		if _, err := auth.EmailChangeConfirm(ctx, tokenSentTo(t, newEmail)); err != nil {
+			t.Fatalf("EmailChangeConfirm() unexpected error: %v", err)
+		}
+
+		reverted, err := auth.EmailChangeRevert(ctx, tokenSentTo(t, user.Email))
+		if err != nil {
+			t.Fatalf("EmailChangeRevert() unexpected error: %v", err)
+		}
+		if reverted.Email != user.Email {
+			t.Errorf("expected email to be reverted to %s, got %s", user.Email, reverted.Email)
+		}
+		if _, err := auth.TokenRefresh(ctx, current.RefreshToken); err == nil {
+			t.Error("expected every session to be revoked")
+		}
+	})
+
+	t.Run("RevertPending", func(t *testing.T) {
+		user, current := newUser(t, "pending-old@example.com")
+		newEmail := "pending-new@example.com"
+		req := RequestEmailChange{Email: newEmail, Password: "password123"}
+		if err := auth.EmailChangeRequest(ctx, user.ID, sidOf(t, current), req); err != nil {
+			t.Fatalf("EmailChangeRequest() unexpected error: %v", err)
+		}
+		confirm := tokenSentTo(t, newEmail)
+
+		if _, err := auth.EmailChangeRevert(ctx, tokenSentTo(t, user.Email)); err != nil {
+			t.Fatalf("EmailChangeRevert() unexpected error: %v", err)
+		}
+		if _, err := auth.EmailChangeConfirm(ctx, confirm); err == nil {
+			t.Error("expected the cancelled change not to be confirmed")
+		}
+	})
+
+	t.Run("Reauthentication", func(t *testing.T) {
+		user, current := newUser(t, "reauth@example.com")
+		sid := sidOf(t, current)
+
+		req := RequestEmailChange{Email: "reauth-new@example.com", Password: "wrong"}
+		if err := auth.EmailChangeRequest(ctx, user.ID, sid, req); !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("expected ErrInvalidPassword, got %v", err)
+		}
+
+		// A session that just logged in does not need the password
+		req.Password = ""
+		if err := auth.EmailChangeRequest(ctx, user.ID, sid, req); err != nil {
+			t.Errorf("expected recent login to be accepted, got %v", err)
+		}
+		if err := auth.EmailChangeRequest(ctx, user.ID, "", req); !errors.Is(err, ErrReauthenticationRequired) {
+			t.Errorf("expected ErrReauthenticationRequired without a session, got %v", err)
+		}
+
+		auth.Cfg.Token.RecentLoginTTL = time.Nanosecond
+		defer func() { auth.Cfg.Token.RecentLoginTTL = 0 }()
+		if err := auth.EmailChangeRequest(ctx, user.ID, sid, req); !errors.Is(err, ErrReauthenticationRequired) {
+			t.Errorf("expected ErrReauthenticationRequired after the window, got %v", err)
+		}
+	})
+
+	t.Run("InvalidEmail", func(t *testing.T) {
+		user, current := newUser(t, "invalid-change@example.com")
+		sid := sidOf(t, current)
+
+		tests := []struct {
+			email string
+			err   error
+		}{
+			{email: "not-an-email", err: ErrInvalidEmail},
+			{email: "Name <name@example.com>", err: ErrInvalidEmail},
+			{email: "INVALID-change@example.com", err: ErrEmailUnchanged},
+		}
+		for _, tt := range tests {
+			req := RequestEmailChange{Email: tt.email, Password: "password123"}
+			if err := auth.EmailChangeRequest(ctx, user.ID, sid, req); !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected %v, got %v", tt.email, tt.err, err)
+			}
+		}
+	})
+
+	t.Run("TakenEmail", func(t *testing.T) {
+		// The request looks the same as for a free address, so it cannot tell which addresses are registered
+		user, current := newUser(t, "taken-change@example.com")
+		owner, _ := newUser(t, "taken-owner@example.com")
+
+		req := RequestEmailChange{Email: owner.Email, Password: "password123"}
+		if err := auth.EmailChangeRequest(ctx, user.ID, sidOf(t, current), req); err != nil {
+			t.Fatalf("EmailChangeRequest() unexpected error: %v", err)
+		}
+
+		attempt := mockMailer.SentEmails[len(mockMailer.SentEmails)-2]
+		notice := mockMailer.SentEmails[len(mockMailer.SentEmails)-1]
+		if attempt["to"] != owner.Email || strings.Contains(attempt["body"], "token=") {
+			t.Errorf("expected a notice without a link to be sent to the owner, got %v", attempt)
+		}
+		if notice["to"] != user.Email || !strings.Contains(notice["body"], "/auth/email/change/revert?token=") {
+			t.Errorf("expected a revert link to be sent to the old address, got %v", notice)
+		}
+
+		tokens, err := auth.Repo.TokenListActive(ctx, user.ID, models.TokenTypeEmailChange)
+		if err != nil {
+			t.Fatalf("TokenListActive() unexpected error: %v", err)
+		}
+		if len(tokens) != 0 {
+			t.Errorf("expected no confirmation token, got %d", len(tokens))
+		}
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		if _, err := auth.EmailChangeConfirm(ctx, "invalid"); err == nil {
+			t.Error("expected invalid token to be rejected")
+		}
+
+		// A revert token cannot confirm a change
+		user, current := newUser(t, "type-old@example.com")
+		req := RequestEmailChange{Email: "type-new@example.com", Password: "password123"}
+		if err := auth.EmailChangeRequest(ctx, user.ID, sidOf(t, current), req); err != nil {
+			t.Fatalf("EmailChangeRequest() unexpected error: %v", err)
+		}
+		if _, err := auth.EmailChangeConfirm(ctx, tokenSentTo(t, user.Email)); err == nil {
+			t.Error("expected revert token to be rejected")
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// EventType identifies a security relevant event emitted by the service.
+type EventType string
+
+const (
+	// EventRefreshTokenReused is emitted when a rotated refresh token is presented again.
+	// The whole token family has been revoked when it fires.
+	EventRefreshTokenReused EventType = "refresh_token.reused"
+)
+
+// Event describes something that happened in the service.
+type Event struct {
+	Type      EventType      `json:"type"`
+	UserID    string         `json:"user_id"`
+	Data      map[string]any `json:"data,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// EventHandler receives the events emitted by the service.
+// Handlers are called synchronously and should return quickly.
+type EventHandler func(ctx context.Context, event Event)
+
+// OnEvent registers a handler for the events emitted by the service.
+// It must be called before the service starts handling requests.
+func (a *Auth) OnEvent(handler EventHandler) {
+	a.eventHandlers = append(a.eventHandlers, handler)
+}
+
+func (a *Auth) emit(ctx context.Context, event Event) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	for _, handler := range a.eventHandlers {
+		handler(ctx, event)
+	}
+}
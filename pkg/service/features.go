@@ -0,0 +1,43 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFeatureDisabled is wrapped by the errors returned by the flows turned off in the Features configuration.
+var ErrFeatureDisabled = errors.New("feature disabled")
+
+var (
+	ErrRegistrationDisabled  = fmt.Errorf("%w: registration", ErrFeatureDisabled)
+	ErrPasswordLoginDisabled = fmt.Errorf("%w: password login", ErrFeatureDisabled)
+	ErrPasswordResetDisabled = fmt.Errorf("%w: password reset", ErrFeatureDisabled)
+	ErrPasswordlessDisabled  = fmt.Errorf("%w: passwordless login", ErrFeatureDisabled)
+	ErrOAuth2Disabled        = fmt.Errorf("%w: oauth2 login", ErrFeatureDisabled)
+)
+
+// RegistrationEnabled reports whether new accounts may be created through registration,
+// passwordless and OAuth2 logins.
+func (a *Auth) RegistrationEnabled() bool {
+	return !a.Cfg.Features.DisableRegistration
+}
+
+// PasswordLoginEnabled reports whether users may log in and register with a password.
+func (a *Auth) PasswordLoginEnabled() bool {
+	return !a.Cfg.Features.DisablePasswordLogin
+}
+
+// PasswordResetEnabled reports whether users may reset their password by email.
+func (a *Auth) PasswordResetEnabled() bool {
+	return !a.Cfg.Features.DisablePasswordReset
+}
+
+// PasswordlessEnabled reports whether users may log in with a magic link.
+func (a *Auth) PasswordlessEnabled() bool {
+	return !a.Cfg.Features.DisablePasswordless
+}
+
+// OAuth2Enabled reports whether users may log in with an OAuth2 provider.
+func (a *Auth) OAuth2Enabled() bool {
+	return !a.Cfg.Features.DisableOAuth2
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/josuebrunel/ezauth/pkg/config"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+func TestFeatures(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+	existing, err := auth.Repo.UserCreate(ctx, &models.User{Email: "features-existing@example.com", Provider: "local"})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		features config.Features
+		call     func() error
+		err      error
+	}{
+		{
+			name:     "Registration",
+			features: config.Features{DisableRegistration: true},
+			call: func() error {
+				_, err := auth.UserCreate(ctx, &RequestBasicAuth{Email: "features-register@example.com", Password: "password123"})
+				return err
+			},
+			err: ErrRegistrationDisabled,
+		},
+		{
+			name:     "RegistrationWithoutPasswordLogin",
+			features: config.Features{DisablePasswordLogin: true},
+			call: func() error {
+				_, err := auth.UserCreate(ctx, &RequestBasicAuth{Email: "features-register@example.com", Password: "password123"})
+				return err
+			},
+			err: ErrPasswordLoginDisabled,
+		},
+		{
+			name:     "PasswordLogin",
+			features: config.Features{DisablePasswordLogin: true},
+			call: func() error {
+				_, err := auth.UserAuthenticate(ctx, RequestBasicAuth{Email: existing.Email, Password: "password123"})
+				return err
+			},
+			err: ErrPasswordLoginDisabled,
+		},
+		{
+			name:     "PasswordResetRequest",
+			features: config.Features{DisablePasswordReset: true},
+			call: func() error {
+				return auth.PasswordResetRequest(ctx, RequestPasswordReset{Email: existing.Email})
+			},
+			err: ErrPasswordResetDisabled,
+		},
+		{
+			name:     "PasswordResetConfirm",
+			features: config.Features{DisablePasswordReset: true},
+			call: func() error {
+				return auth.PasswordResetConfirm(ctx, RequestPasswordResetConfirm{Token: "token", Password: "password123"})
+			},
+			err: ErrPasswordResetDisabled,
+		},
+		{
+			name:     "PasswordlessRequest",
+			features: config.Features{DisablePasswordless: true},
+			call: func() error {
+				return auth.PasswordlessRequest(ctx, RequestPasswordless{Email: existing.Email})
+			},
+			err: ErrPasswordlessDisabled,
+		},
+		{
+			name:     "PasswordlessLogin",
+			features: config.Features{DisablePasswordless: true},
+			call: func() error {
+				_, err := auth.PasswordlessLogin(ctx, "token")
+				return err
+			},
+			err: ErrPasswordlessDisabled,
+		},
+		{
+			name:     "OAuth2Config",
+			features: config.Features{DisableOAuth2: true},
+			call: func() error {
+				_, err := auth.OAuth2GetConfig("google")
+				return err
+			},
+			err: ErrOAuth2Disabled,
+		},
+		{
+			name:     "OAuth2Authenticate",
+			features: config.Features{DisableOAuth2: true},
+			call: func() error {
+				_, err := auth.OAuth2Authenticate(ctx, "github", &OAuth2UserInfo{ID: "features-1", Email: existing.Email})
+				return err
+			},
+			err: ErrOAuth2Disabled,
+		},
+		{
+			name:     "OAuth2Registration",
+			features: config.Features{DisableRegistration: true},
+			call: func() error {
+				_, err := auth.OAuth2Authenticate(ctx, "github", &OAuth2UserInfo{ID: "features-2", Email: "features-oauth2@example.com"})
+				return err
+			},
+			err: ErrRegistrationDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth.Cfg.Features = tt.features
+			defer func() { auth.Cfg.Features = config.Features{} }()
+
+			err := tt.call()
+			if !errors.Is(err, tt.err) || !errors.Is(err, ErrFeatureDisabled) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("OAuth2LoginWithoutRegistration", func(t *testing.T) {
+		auth.Cfg.Features = config.Features{DisableRegistration: true}
+		defer func() { auth.Cfg.Features = config.Features{} }()
+
+		user, err := auth.OAuth2Authenticate(ctx, "github", &OAuth2UserInfo{ID: "features-3", Email: existing.Email})
+		if err != nil {
+			t.Fatalf("expected existing user to log in, got %v", err)
+		}
+		if user.ID != existing.ID {
+			t.Errorf("expected user %s, got %s", existing.ID, user.ID)
+		}
+	})
+
+	t.Run("PasswordlessWithoutRegistration", func(t *testing.T) {
+		auth.Cfg.Features = config.Features{DisableRegistration: true}
+		defer func() { auth.Cfg.Features = config.Features{} }()
+
+		mailer := auth.Mailer.(*MockMailer)
+		sent := len(mailer.SentEmails)
+		if err := auth.PasswordlessRequest(ctx, RequestPasswordless{Email: "features-unknown@example.com"}); err != nil {
+			t.Fatalf("PasswordlessRequest() unexpected error: %v", err)
+		}
+		if len(mailer.SentEmails) != sent {
+			t.Error("expected no magic link to be sent to an unknown email")
+		}
+		if err := auth.PasswordlessRequest(ctx, RequestPasswordless{Email: existing.Email}); err != nil {
+			t.Fatalf("PasswordlessRequest() unexpected error: %v", err)
+		}
+		if len(mailer.SentEmails) != sent+1 {
+			t.Error("expected a magic link to be sent to an existing user")
+		}
+	})
+}
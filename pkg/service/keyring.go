@@ -0,0 +1,221 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/config"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+var ErrUnknownKeyID = errors.New("unknown signing key id")
+
+// Keyring holds the active signing key and any number of verify-only keys.
+// Tokens are always signed with the active key; verification picks the key
+// matching the token's kid header, so retired keys keep validating the tokens
+// they issued until those expire.
+type Keyring struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewKeyring builds a keyring from the JWT configuration.
+// The active key comes from JWTSecret or JWT.PrivateKeyFile, verify-only keys
+// from JWT.PreviousSecrets and JWT.VerifyKeyFiles.
+func NewKeyring(cfg *config.Config) (*Keyring, error) {
+	k := &Keyring{}
+	if err := k.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload re-reads the keys from the configuration and swaps them in atomically.
+// On error the current keys are left untouched.
+func (k *Keyring) Reload(cfg *config.Config) error {
+	active, err := NewSigningKey(cfg)
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*SigningKey{active.ID: active}
+
+	for _, secret := range cfg.JWT.PreviousSecrets {
+		if secret == "" {
+			continue
+		}
+		key := newHMACKey(secret)
+		key.PrivateKey = nil
+		keys[key.ID] = key
+	}
+
+	for _, pattern := range cfg.JWT.VerifyKeyFiles {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid verify key pattern %q: %w", pattern, err)
+		}
+		for _, path := range paths {
+			key, err := loadVerifyKey(path)
+			if err != nil {
+				return fmt.Errorf("failed to load verify key %s: %w", path, err)
+			}
+			if _, ok := keys[key.ID]; !ok {
+				keys[key.ID] = key
+			}
+		}
+	}
+
+	k.mu.Lock()
+	k.active = active
+	k.keys = keys
+	k.mu.Unlock()
+
+	xlog.Info("jwt keyring loaded", "active", active.ID, "keys", len(keys))
+	return nil
+}
+
+// Active returns the key used to sign new tokens.
+func (k *Keyring) Active() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Get returns the key with the given kid.
+func (k *Keyring) Get(kid string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// Keys returns all keys accepted for verification, the active key first.
+func (k *Keyring) Keys() []*SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := []*SigningKey{k.active}
+	for id, key := range k.keys {
+		if id != k.active.ID {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// RotateKeyFile retires the private key at path and replaces it with a freshly generated one.
+// The public part of the retired key is written to retiredDir as <kid>.pem so it can be
+// listed in JWT_VERIFY_KEY_FILES until the tokens it signed have expired.
+// It returns the kids of the retired and the new key.
+func RotateKeyFile(alg, path, retiredDir string) (string, string, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || (alg != AlgRS256 && alg != AlgES256 && alg != AlgEdDSA) {
+		return "", "", fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+	}
+
+	var retiredID string
+	_, err := os.Stat(path)
+	switch {
+	case err == nil:
+		old, err := loadVerifyKey(path)
+		if err != nil {
+			return "", "", err
+		}
+		der, err := x509.MarshalPKIXPublicKey(old.PublicKey)
+		if err != nil {
+			return "", "", err
+		}
+		if err := os.MkdirAll(retiredDir, 0o700); err != nil {
+			return "", "", err
+		}
+		retired := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+		if err := os.WriteFile(filepath.Join(retiredDir, old.ID+".pem"), retired, 0o644); err != nil {
+			return "", "", err
+		}
+		retiredID = old.ID
+	case !errors.Is(err, os.ErrNotExist):
+		return "", "", err
+	}
+
+	key, err := generateSigningKey(method)
+	if err != nil {
+		return "", "", err
+	}
+	if err := writePrivateKeyFile(path, key.PrivateKey); err != nil {
+		return "", "", err
+	}
+	return retiredID, key.ID, nil
+}
+
+// LoadKeyFile reads the PEM encoded public or private key at path as a verify-only key,
+// for instance to print its kid or JWK.
+func LoadKeyFile(path string) (*SigningKey, error) {
+	return loadVerifyKey(path)
+}
+
+// loadVerifyKey reads a PEM encoded public or private key and returns it as a verify-only key.
+// The algorithm is derived from the key type.
+func loadVerifyKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var pub any
+	switch block.Type {
+	case "PUBLIC KEY":
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+		var priv any
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			if priv, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				priv, err = x509.ParseECPrivateKey(block.Bytes)
+			}
+		}
+		if signer, ok := priv.(crypto.Signer); ok && err == nil {
+			pub = signer.Public()
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var method jwt.SigningMethod
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if p.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 EC keys are supported")
+		}
+		method = jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	return newSigningKey(method, nil, pub), nil
+}
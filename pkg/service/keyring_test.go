@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/config"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+func TestKeyring(t *testing.T) {
+	user := &models.User{ID: "user-123", Email: "keyring@example.com"}
+
+	t.Run("RotateKeyFile", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := &config.Config{
+			JWT: config.JWT{
+				Algorithm:      AlgES256,
+				PrivateKeyFile: filepath.Join(dir, "jwt.pem"),
+				VerifyKeyFiles: []string{filepath.Join(dir, "retired", "*.pem")},
+			},
+		}
+
+		keys, err := NewKeyring(cfg)
+		if err != nil {
+			t.Fatalf("NewKeyring() unexpected error: %v", err)
+		}
+		auth := &Auth{Cfg: cfg, Keys: keys}
+
+		oldToken, _, err := auth.generateAccessToken(context.Background(), user, time.Hour, "")
+		if err != nil {
+			t.Fatalf("generateAccessToken() unexpected error: %v", err)
+		}
+		oldKid := keys.Active().ID
+
+		retired, active, err := RotateKeyFile(AlgEdDSA, cfg.JWT.PrivateKeyFile, filepath.Join(dir, "retired"))
+		if err != nil {
+			t.Fatalf("RotateKeyFile() unexpected error: %v", err)
+		}
+		if retired != oldKid {
+			t.Errorf("expected retired kid %s, got %s", oldKid, retired)
+		}
+
+		cfg.JWT.Algorithm = AlgEdDSA
+		if err := auth.KeysReload(); err != nil {
+			t.Fatalf("KeysReload() unexpected error: %v", err)
+		}
+		if keys.Active().ID != active {
+			t.Errorf("expected active kid %s, got %s", active, keys.Active().ID)
+		}
+
+		newToken, _, err := auth.generateAccessToken(context.Background(), user, time.Hour, "")
+		if err != nil {
+			t.Fatalf("generateAccessToken() unexpected error: %v", err)
+		}
+		parsed, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+		if err != nil {
+			t.Fatalf("failed to parse token: %v", err)
+		}
+		if parsed.Header["kid"] != active {
+			t.Errorf("expected kid header %s, got %v", active, parsed.Header["kid"])
+		}
+
+		for name, tokenString := range map[string]string{"old": oldToken, "new": newToken} {
+			if _, err := auth.AccessTokenParse(tokenString); err != nil {
+				t.Errorf("expected %s token to verify after rotation, got %v", name, err)
+			}
+		}
+
+		if n := len(auth.JWKS().Keys); n != 2 {
+			t.Errorf("expected 2 published keys, got %d", n)
+		}
+	})
+
+	t.Run("PreviousSecrets", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "old-secret"}
+		keys, err := NewKeyring(cfg)
+		if err != nil {
+			t.Fatalf("NewKeyring() unexpected error: %v", err)
+		}
+		auth := &Auth{Cfg: cfg, Keys: keys}
+
+		oldToken, _, err := auth.generateAccessToken(context.Background(), user, time.Hour, "")
+		if err != nil {
+			t.Fatalf("generateAccessToken() unexpected error: %v", err)
+		}
+
+		cfg.JWTSecret = "new-secret"
+		if err := auth.KeysReload(); err != nil {
+			t.Fatalf("KeysReload() unexpected error: %v", err)
+		}
+		if _, err := auth.AccessTokenParse(oldToken); err == nil {
+			t.Error("expected token signed with a dropped secret to be rejected")
+		}
+
+		cfg.JWT.PreviousSecrets = []string{"old-secret"}
+		if err := auth.KeysReload(); err != nil {
+			t.Fatalf("KeysReload() unexpected error: %v", err)
+		}
+		if _, err := auth.AccessTokenParse(oldToken); err != nil {
+			t.Errorf("expected token signed with a previous secret to verify, got %v", err)
+		}
+		if n := len(auth.JWKS().Keys); n != 0 {
+			t.Errorf("expected secrets to never be published, got %d keys", n)
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/config"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+// Supported JWT signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnsupportedAlgorithm    = errors.New("unsupported jwt algorithm")
+	ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
+)
+
+// SigningKey holds the key material used to sign and verify access tokens.
+// For HS256 both keys are the shared secret. For asymmetric algorithms
+// PrivateKey is a crypto.Signer and PublicKey its public counterpart.
+// PrivateKey is nil for verify-only keys. ID is stamped as the kid header.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey any
+	PublicKey  any
+}
+
+// JWK is a JSON Web Key as defined in RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set as served on /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewSigningKey builds the signing key described by the JWT configuration.
+// Asymmetric keys are read from cfg.JWT.PrivateKeyFile, generated and written
+// there if the file does not exist yet, or generated in memory if no file is set.
+func NewSigningKey(cfg *config.Config) (*SigningKey, error) {
+	alg := cfg.JWT.Algorithm
+	if alg == "" {
+		alg = AlgHS256
+	}
+
+	if alg == AlgHS256 {
+		return newHMACKey(cfg.JWTSecret), nil
+	}
+
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || (alg != AlgRS256 && alg != AlgES256 && alg != AlgEdDSA) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+	}
+
+	path := cfg.JWT.PrivateKeyFile
+	if path == "" {
+		xlog.Warn("no jwt private key file configured, generating an ephemeral key", "alg", alg)
+		return generateSigningKey(method)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := generateSigningKey(method)
+		if err != nil {
+			return nil, err
+		}
+		if err := writePrivateKeyFile(path, key.PrivateKey); err != nil {
+			return nil, err
+		}
+		xlog.Info("generated jwt private key", "alg", alg, "path", path)
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSigningKey(method, data)
+}
+
+// JWK returns the public part of the key as a JWK.
+// It returns false for symmetric keys, which must never be published.
+func (k *SigningKey) JWK() (JWK, bool) {
+	jwk := JWK{Use: "sig", Alg: k.Method.Alg(), Kid: k.ID}
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		ecdhPub, err := pub.ECDH()
+		if err != nil {
+			return JWK{}, false
+		}
+		// Uncompressed point: 0x04 || X || Y
+		b := ecdhPub.Bytes()
+		size := (len(b) - 1) / 2
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(b[1 : 1+size])
+		jwk.Y = b64(b[1+size:])
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+func generateSigningKey(method jwt.SigningMethod) (*SigningKey, error) {
+	var (
+		priv crypto.Signer
+		err  error
+	)
+
+	switch method.Alg() {
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, method.Alg())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newSigningKey(method, priv, priv.Public()), nil
+}
+
+func parseSigningKey(method jwt.SigningMethod, data []byte) (*SigningKey, error) {
+	var (
+		priv crypto.Signer
+		err  error
+	)
+
+	switch method.Alg() {
+	case AlgRS256:
+		priv, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+	case AlgES256:
+		var key *ecdsa.PrivateKey
+		key, err = jwt.ParseECPrivateKeyFromPEM(data)
+		if err == nil && key.Curve != elliptic.P256() {
+			err = errors.New("ES256 requires a P-256 key")
+		}
+		priv = key
+	case AlgEdDSA:
+		var key crypto.PrivateKey
+		key, err = jwt.ParseEdPrivateKeyFromPEM(data)
+		if err == nil {
+			priv, _ = key.(crypto.Signer)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, method.Alg())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s private key: %w", method.Alg(), err)
+	}
+
+	return newSigningKey(method, priv, priv.Public()), nil
+}
+
+func newSigningKey(method jwt.SigningMethod, priv, pub any) *SigningKey {
+	key := &SigningKey{Method: method, PrivateKey: priv, PublicKey: pub}
+	key.ID = keyThumbprint(key)
+	return key
+}
+
+func newHMACKey(secret string) *SigningKey {
+	sum := sha256.Sum256([]byte(secret))
+	return &SigningKey{
+		ID:         "hs-" + b64(sum[:8]),
+		Method:     jwt.SigningMethodHS256,
+		PrivateKey: []byte(secret),
+		PublicKey:  []byte(secret),
+	}
+}
+
+// keyThumbprint computes the RFC 7638 JWK thumbprint of an asymmetric key.
+func keyThumbprint(key *SigningKey) string {
+	jwk, ok := key.JWK()
+	if !ok {
+		return ""
+	}
+
+	// Required members only, in lexicographic order
+	var members string
+	switch jwk.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, jwk.E, jwk.Kty, jwk.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Crv, jwk.Kty, jwk.X, jwk.Y)
+	default:
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, jwk.Crv, jwk.Kty, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return b64(sum[:])
+}
+
+func writePrivateKeyFile(path string, key any) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(path, data, 0o600)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
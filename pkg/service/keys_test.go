@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/config"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+func TestSigningKeys(t *testing.T) {
+	tests := []struct {
+		alg string
+		kty string
+	}{
+		{alg: AlgHS256},
+		{alg: AlgRS256, kty: "RSA"},
+		{alg: AlgES256, kty: "EC"},
+		{alg: AlgEdDSA, kty: "OKP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			cfg := &config.Config{
+				JWTSecret: "test-secret",
+				JWT: config.JWT{
+					Algorithm:      tt.alg,
+					PrivateKeyFile: filepath.Join(t.TempDir(), "keys", "jwt.pem"),
+				},
+			}
+
+			keys, err := NewKeyring(cfg)
+			if err != nil {
+				t.Fatalf("NewKeyring() unexpected error: %v", err)
+			}
+
+			auth := &Auth{Cfg: cfg, Keys: keys}
+			user := &models.User{ID: "user-123", Email: "keys@example.com"}
+			tokenString, _, err := auth.generateAccessToken(context.Background(), user, time.Hour, "")
+			if err != nil {
+				t.Fatalf("generateAccessToken() unexpected error: %v", err)
+			}
+
+			claims, err := auth.AccessTokenParse(tokenString)
+			if err != nil {
+				t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+			}
+			if claims["sub"] != user.ID {
+				t.Errorf("expected sub %s, got %v", user.ID, claims["sub"])
+			}
+
+			jwks := auth.JWKS()
+			if tt.kty == "" {
+				if len(jwks.Keys) != 0 {
+					t.Errorf("expected no published keys for %s, got %d", tt.alg, len(jwks.Keys))
+				}
+				return
+			}
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != tt.kty || jwks.Keys[0].Alg != tt.alg {
+				t.Errorf("unexpected jwks: %+v", jwks)
+			}
+
+			// The generated key must have been persisted and be reused on the next start
+			reloaded, err := NewKeyring(cfg)
+			if err != nil {
+				t.Fatalf("NewKeyring() reload unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(reloaded.Active().PublicKey, keys.Active().PublicKey) {
+				t.Error("expected reloaded key to match the generated one")
+			}
+			if _, err := (&Auth{Cfg: cfg, Keys: reloaded}).AccessTokenParse(tokenString); err != nil {
+				t.Errorf("expected token to verify with reloaded key, got %v", err)
+			}
+		})
+	}
+
+	t.Run("AlgorithmMismatch", func(t *testing.T) {
+		rsaCfg := &config.Config{JWT: config.JWT{Algorithm: AlgRS256}}
+		rsaKeys, err := NewKeyring(rsaCfg)
+		if err != nil {
+			t.Fatalf("NewKeyring() unexpected error: %v", err)
+		}
+		hmacCfg := &config.Config{JWTSecret: "test-secret"}
+		hmacKeys, err := NewKeyring(hmacCfg)
+		if err != nil {
+			t.Fatalf("NewKeyring() unexpected error: %v", err)
+		}
+
+		tokenString, _, err := (&Auth{Cfg: hmacCfg, Keys: hmacKeys}).generateAccessToken(context.Background(), &models.User{ID: "user-123"}, time.Hour, "")
+		if err != nil {
+			t.Fatalf("generateAccessToken() unexpected error: %v", err)
+		}
+		if _, err := (&Auth{Cfg: rsaCfg, Keys: rsaKeys}).AccessTokenParse(tokenString); err == nil {
+			t.Error("expected HS256 token to be rejected by an RS256 verifier")
+		}
+	})
+
+	t.Run("UnsupportedAlgorithm", func(t *testing.T) {
+		if _, err := NewSigningKey(&config.Config{JWT: config.JWT{Algorithm: "none"}}); err == nil {
+			t.Error("expected error for unsupported algorithm")
+		}
+	})
+}
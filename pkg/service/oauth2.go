@@ -23,6 +23,10 @@ type OAuth2UserInfo struct {
 
 // OAuth2GetConfig returns the OAuth2 configuration for the given provider.
 func (a *Auth) OAuth2GetConfig(provider string) (*oauth2.Config, error) {
+	if !a.OAuth2Enabled() {
+		return nil, ErrOAuth2Disabled
+	}
+
 	switch provider {
 	case "google":
 		return &oauth2.Config{
@@ -119,10 +123,18 @@ func (a *Auth) OAuth2GetUserInfo(ctx context.Context, provider string, token *oa
 
 // OAuth2Authenticate authenticates a user using OAuth2 information.
 // It links the OAuth2 account to an existing user or creates a new one.
+// It returns ErrUserDeleted if the account is pending deletion.
 func (a *Auth) OAuth2Authenticate(ctx context.Context, provider string, userInfo *OAuth2UserInfo) (*models.User, error) {
+	if !a.OAuth2Enabled() {
+		return nil, ErrOAuth2Disabled
+	}
+
 	// 1. Try to find user by provider and provider ID
 	user, err := a.Repo.UserGetByProvider(ctx, provider, userInfo.ID)
 	if err == nil && user != nil {
+		if user.DeletedAt != nil {
+			return nil, ErrUserDeleted
+		}
 		// User found, update email if it changed
 		if userInfo.Email != "" && user.Email != userInfo.Email {
 			user.Email = userInfo.Email
@@ -135,6 +147,9 @@ func (a *Auth) OAuth2Authenticate(ctx context.Context, provider string, userInfo
 	if userInfo.Email != "" {
 		user, err = a.Repo.UserGetByEmail(ctx, userInfo.Email)
 		if err == nil && user != nil {
+			if user.DeletedAt != nil {
+				return nil, ErrUserDeleted
+			}
 			// Found by email, link provider
 			user.Provider = provider
 			user.ProviderID = &userInfo.ID
@@ -143,6 +158,9 @@ func (a *Auth) OAuth2Authenticate(ctx context.Context, provider string, userInfo
 	}
 
 	// 3. Create new user
+	if !a.RegistrationEnabled() {
+		return nil, ErrRegistrationDisabled
+	}
 	user = &models.User{
 		Email:         userInfo.Email,
 		Provider:      provider,
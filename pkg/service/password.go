@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/gopkg/xlog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultPasswordMinLength is used when the configuration leaves Password.MinLength unset.
+const DefaultPasswordMinLength = 8
+
+// passwordMaxLength is the longest password bcrypt can hash, in bytes.
+const passwordMaxLength = 72
+
+var (
+	ErrInvalidPassword  = errors.New("invalid password")
+	ErrPasswordTooShort = errors.New("password too short")
+	ErrPasswordTooLong  = errors.New("password too long")
+)
+
+// RequestPasswordChange defines the parameters for changing the password of an authenticated user.
+// RevokeOtherSessions logs the user out of every other device.
+type RequestPasswordChange struct {
+	CurrentPassword     string `json:"current_password"`
+	Password            string `json:"password"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions"`
+}
+
+// PasswordValidate checks a password against the password policy.
+// It returns an error wrapping ErrPasswordTooShort or ErrPasswordTooLong.
+func (a *Auth) PasswordValidate(password string) error {
+	minLength := a.Cfg.Password.MinLength
+	if minLength <= 0 {
+		minLength = DefaultPasswordMinLength
+	}
+	if utf8.RuneCountInString(password) < minLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPasswordTooShort, minLength)
+	}
+	if len(password) > passwordMaxLength {
+		return fmt.Errorf("%w: must be at most %d bytes", ErrPasswordTooLong, passwordMaxLength)
+	}
+	return nil
+}
+
+// PasswordChange changes the password of a user after checking their current one, and emails them a notice.
+// With RevokeOtherSessions, every session but the one with ID currentID is logged out.
+func (a *Auth) PasswordChange(ctx context.Context, userID, currentID string, req RequestPasswordChange) error {
+	if !a.PasswordLoginEnabled() {
+		return ErrPasswordLoginDisabled
+	}
+
+	user, err := a.Repo.UserGetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := passwordCheck(user, req.CurrentPassword); err != nil {
+		return err
+	}
+
+	if err := a.PasswordValidate(req.Password); err != nil {
+		return err
+	}
+
+	user, err = a.UserUpdatePassword(ctx, user, req.Password)
+	if err != nil {
+		return err
+	}
+
+	if req.RevokeOtherSessions {
+		if err := a.SessionRevokeOthers(ctx, user.ID, currentID); err != nil {
+			return err
+		}
+	}
+
+	xlog.Info("password changed", "user_id", user.ID)
+
+	// The password is changed even if the notice could not be sent
+	subject := "Your password was changed"
+	body := "The password of your account was just changed. If you did not make this change, reset your password right away."
+	if err := a.Mailer.Send(user.Email, subject, body); err != nil {
+		xlog.Error("failed to send password changed email", "error", err, "user_id", user.ID)
+	}
+	return nil
+}
+
+// passwordCheck compares a password with the one of the user.
+// Users without a password, such as OAuth2 ones, never match.
+func passwordCheck(user *models.User, password string) error {
+	if user.PasswordHash == "" || password == "" {
+		return ErrInvalidPassword
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return ErrInvalidPassword
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordValidate(t *testing.T) {
+	auth := setupTestDB(t)
+
+	tests := []struct {
+		name      string
+		minLength int
+		password  string
+		err       error
+	}{
+		{name: "Default", password: "12345678"},
+		{name: "DefaultTooShort", password: "1234567", err: ErrPasswordTooShort},
+		{name: "Configured", minLength: 12, password: "12345678", err: ErrPasswordTooShort},
+		{name: "CountsCharacters", password: "éééééééé"},
+		{name: "TooLong", password: strings.Repeat("a", 73), err: ErrPasswordTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth.Cfg.Password.MinLength = tt.minLength
+			defer func() { auth.Cfg.Password.MinLength = 0 }()
+
+			if err := auth.PasswordValidate(tt.password); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestPasswordChange(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+	mockMailer := auth.Mailer.(*MockMailer)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "password-change@example.com", Provider: "local", PasswordHash: string(hash)})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	current, err := auth.TokenCreate(ctx, user)
+	if err != nil {
+		t.Fatalf("TokenCreate() unexpected error: %v", err)
+	}
+	other, err := auth.TokenCreate(ctx, user)
+	if err != nil {
+		t.Fatalf("TokenCreate() unexpected error: %v", err)
+	}
+	claims, err := auth.AccessTokenParse(current.AccessToken)
+	if err != nil {
+		t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+	}
+	sid, _ := claims["sid"].(string)
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		req := RequestPasswordChange{CurrentPassword: "wrong", Password: "new-password"}
+		if err := auth.PasswordChange(ctx, user.ID, sid, req); !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("expected ErrInvalidPassword, got %v", err)
+		}
+	})
+
+	t.Run("Policy", func(t *testing.T) {
+		req := RequestPasswordChange{CurrentPassword: "password123", Password: "short"}
+		if err := auth.PasswordChange(ctx, user.ID, sid, req); !errors.Is(err, ErrPasswordTooShort) {
+			t.Errorf("expected ErrPasswordTooShort, got %v", err)
+		}
+	})
+
+	t.Run("Change", func(t *testing.T) {
+		req := RequestPasswordChange{CurrentPassword: "password123", Password: "new-password", RevokeOtherSessions: true}
+		if err := auth.PasswordChange(ctx, user.ID, sid, req); err != nil {
+			t.Fatalf("PasswordChange() unexpected error: %v", err)
+		}
+
+		if _, err := auth.UserAuthenticate(ctx, RequestBasicAuth{Email: user.Email, Password: "new-password"}); err != nil {
+			t.Errorf("expected new password to work, got %v", err)
+		}
+		if _, err := auth.UserAuthenticate(ctx, RequestBasicAuth{Email: user.Email, Password: "password123"}); err == nil {
+			t.Error("expected old password to be rejected")
+		}
+
+		if _, err := auth.TokenRefresh(ctx, other.RefreshToken); err == nil {
+			t.Error("expected other session to be revoked")
+		}
+		if _, err := auth.TokenRefresh(ctx, current.RefreshToken); err != nil {
+			t.Errorf("expected current session to be kept, got %v", err)
+		}
+
+		sent := mockMailer.SentEmails[len(mockMailer.SentEmails)-1]
+		if sent["to"] != user.Email || sent["subject"] != "Your password was changed" {
+			t.Errorf("expected a password changed notice, got %v", sent)
+		}
+	})
+
+	t.Run("NoPassword", func(t *testing.T) {
+		oauth2User, err := auth.Repo.UserCreate(ctx, &models.User{Email: "password-change-oauth2@example.com", Provider: "github"})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		req := RequestPasswordChange{Password: "new-password"}
+		if err := auth.PasswordChange(ctx, oauth2User.ID, "", req); !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("expected ErrInvalidPassword, got %v", err)
+		}
+	})
+}
@@ -21,17 +21,33 @@ type RequestPasswordlessLogin struct {
 }
 
 // PasswordlessRequest initiates the passwordless (magic link) login flow.
-func (a *Auth) PasswordlessRequest(ctx context.Context, req RequestPasswordless) error {
+// The link lifetime defaults to the configuration and can be overridden with WithTokenTTL.
+func (a *Auth) PasswordlessRequest(ctx context.Context, req RequestPasswordless, opts ...TokenOption) error {
+	if !a.PasswordlessEnabled() {
+		return ErrPasswordlessDisabled
+	}
+	if !a.RegistrationEnabled() {
+		if _, err := a.Repo.UserGetByEmail(ctx, req.Email); err != nil {
+			// The link could not create the account; don't leak whether the user exists
+			return nil
+		}
+	}
+
 	tokenValue, err := a.generateRefreshToken()
 	if err != nil {
 		return err
 	}
 
+	o := tokenOptions{ttl: ttlOrDefault(a.Cfg.Token.MagicLinkTTL, DefaultMagicLinkTTL)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	token := &models.PasswordlessToken{
 		Email:     req.Email,
 		Token:     tokenValue,
-		ExpiresAt: time.Now().Add(15 * time.Minute),
-		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().UTC().Add(o.ttl),
+		CreatedAt: time.Now().UTC(),
 	}
 
 	if _, err := a.Repo.PasswordlessTokenCreate(ctx, token); err != nil {
@@ -40,7 +56,12 @@ func (a *Auth) PasswordlessRequest(ctx context.Context, req RequestPasswordless)
 
 	// Send email
 	subject := "Magic Link Login"
+	body := fmt.Sprintf("Click the following link to login: %s", a.link("/passwordless/login", tokenValue))
+	return a.Mailer.Send(req.Email, subject, body)
+}
 
+// link returns the URL of an auth route carrying the given token, for links sent by email.
+func (a *Auth) link(path, token string) string {
 	prefix := a.PathPrefix
 	if prefix != "" {
 		if !strings.HasPrefix(prefix, "/") {
@@ -50,13 +71,16 @@ func (a *Auth) PasswordlessRequest(ctx context.Context, req RequestPasswordless)
 			prefix = strings.TrimSuffix(prefix, "/")
 		}
 	}
-
-	body := fmt.Sprintf("Click the following link to login: %s%s/passwordless/login?token=%s", a.Cfg.BaseURL, prefix, tokenValue)
-	return a.Mailer.Send(req.Email, subject, body)
+	return fmt.Sprintf("%s%s%s?token=%s", a.Cfg.BaseURL, prefix, path, token)
 }
 
 // PasswordlessLogin completes the passwordless login flow.
-func (a *Auth) PasswordlessLogin(ctx context.Context, tokenValue string) (*TokenResponse, error) {
+// opts override the lifetimes of the session tokens, as with TokenCreate.
+func (a *Auth) PasswordlessLogin(ctx context.Context, tokenValue string, opts ...TokenOption) (*TokenResponse, error) {
+	if !a.PasswordlessEnabled() {
+		return nil, ErrPasswordlessDisabled
+	}
+
 	token, err := a.Repo.PasswordlessTokenGetByToken(ctx, tokenValue)
 	if err != nil {
 		return nil, errors.New("invalid or expired magic link")
@@ -71,6 +95,9 @@ func (a *Auth) PasswordlessLogin(ctx context.Context, tokenValue string) (*Token
 	user, err := a.Repo.UserGetByEmail(ctx, token.Email)
 	if err != nil {
 		// User doesn't exist, create one
+		if !a.RegistrationEnabled() {
+			return nil, ErrRegistrationDisabled
+		}
 		user = &models.User{
 			Email:         token.Email,
 			Provider:      "local",
@@ -88,5 +115,5 @@ func (a *Auth) PasswordlessLogin(ctx context.Context, tokenValue string) (*Token
 	}
 
 	// Create session
-	return a.TokenCreate(ctx, user)
+	return a.TokenCreate(ctx, user, opts...)
 }
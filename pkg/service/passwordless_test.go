@@ -33,6 +33,14 @@ func TestPasswordless(t *testing.T) {
 		t.Errorf("expected email body to contain path '%s', got '%s'", expectedPath, sentBody)
 	}
 
+	stored, err := auth.Repo.PasswordlessTokenGetByToken(ctx, tokenValue)
+	if err != nil {
+		t.Fatalf("failed to get passwordless token: %v", err)
+	}
+	if stored.Token == tokenValue {
+		t.Error("expected the magic link token to be stored hashed")
+	}
+
 	// 2. Login with magic link
 	resp, err := auth.PasswordlessLogin(ctx, tokenValue)
 	if err != nil {
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+var (
+	ErrRoleNotFound          = errors.New("role not found")
+	ErrPermissionNotFound    = errors.New("permission not found")
+	ErrInvalidRoleName       = errors.New("role name must not be empty or contain commas")
+	ErrInvalidPermissionName = errors.New("permission name must not be empty")
+)
+
+// RoleCreate creates a role users can be given with UserRoleAdd.
+// Role names are stored in the comma-separated roles of users, so they cannot contain commas.
+func (a *Auth) RoleCreate(ctx context.Context, name, description string) (*models.Role, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Contains(name, ",") {
+		return nil, ErrInvalidRoleName
+	}
+	return a.Repo.RoleCreate(ctx, &models.Role{Name: name, Description: description, CreatedAt: time.Now().UTC()})
+}
+
+// RoleList returns all the roles.
+func (a *Auth) RoleList(ctx context.Context) ([]*models.Role, error) {
+	return a.Repo.RoleList(ctx)
+}
+
+// RoleDelete deletes a role and its permission grants.
+// Users keep the role name in their roles but no longer get its permissions.
+func (a *Auth) RoleDelete(ctx context.Context, name string) error {
+	role, err := a.roleGet(ctx, name)
+	if err != nil {
+		return err
+	}
+	return a.Repo.RoleDelete(ctx, role.ID)
+}
+
+// PermissionCreate creates a permission, such as invoices:read, that can be granted to roles.
+func (a *Auth) PermissionCreate(ctx context.Context, name, description string) (*models.Permission, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidPermissionName
+	}
+	return a.Repo.PermissionCreate(ctx, &models.Permission{Name: name, Description: description, CreatedAt: time.Now().UTC()})
+}
+
+// PermissionList returns all the permissions.
+func (a *Auth) PermissionList(ctx context.Context) ([]*models.Permission, error) {
+	return a.Repo.PermissionList(ctx)
+}
+
+// PermissionDelete deletes a permission and revokes it from every role.
+func (a *Auth) PermissionDelete(ctx context.Context, name string) error {
+	permission, err := a.permissionGet(ctx, name)
+	if err != nil {
+		return err
+	}
+	return a.Repo.PermissionDelete(ctx, permission.ID)
+}
+
+// RolePermissionGrant grants a permission to a role.
+// Users of the role get the permission in the access tokens issued from then on.
+func (a *Auth) RolePermissionGrant(ctx context.Context, roleName, permissionName string) error {
+	role, permission, err := a.rolePermissionGet(ctx, roleName, permissionName)
+	if err != nil {
+		return err
+	}
+	return a.Repo.RolePermissionAdd(ctx, role.ID, permission.ID)
+}
+
+// RolePermissionRevoke revokes a permission from a role.
+// Access tokens already issued keep the permission until they expire.
+func (a *Auth) RolePermissionRevoke(ctx context.Context, roleName, permissionName string) error {
+	role, permission, err := a.rolePermissionGet(ctx, roleName, permissionName)
+	if err != nil {
+		return err
+	}
+	return a.Repo.RolePermissionRemove(ctx, role.ID, permission.ID)
+}
+
+// UserRoleAdd gives a role to a user. The role must have been created with RoleCreate.
+// The user gets the role and its permissions in the access tokens issued from then on.
+func (a *Auth) UserRoleAdd(ctx context.Context, userID, roleName string) (*models.User, error) {
+	role, err := a.roleGet(ctx, roleName)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.Repo.UserGetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Roles.Has(role.Name) {
+		return user, nil
+	}
+
+	user.Roles = append(user.Roles, role.Name)
+	return a.Repo.UserUpdate(ctx, user)
+}
+
+// UserRoleRemove takes a role away from a user.
+// The access tokens of the user are revoked so that they stop carrying the role;
+// clients get new ones with their refresh tokens.
+func (a *Auth) UserRoleRemove(ctx context.Context, userID, roleName string) (*models.User, error) {
+	user, err := a.Repo.UserGetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Roles.Has(roleName) {
+		return user, nil
+	}
+
+	user.Roles = slices.DeleteFunc(slices.Clone(user.Roles), func(role string) bool { return role == roleName })
+	user, err = a.Repo.UserUpdate(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.AccessTokenRevokeAll(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	xlog.Info("role removed from user", "user_id", user.ID, "role", roleName)
+	return user, nil
+}
+
+// UserPermissions returns the names of the permissions granted to the roles of a user.
+// Roles are only given server-side with UserRoleAdd, so users cannot grant themselves permissions.
+func (a *Auth) UserPermissions(ctx context.Context, user *models.User) ([]string, error) {
+	if len(user.Roles) == 0 {
+		return []string{}, nil
+	}
+	permissions, err := a.Repo.PermissionListByRoles(ctx, user.Roles)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.Name)
+	}
+	return names, nil
+}
+
+func (a *Auth) roleGet(ctx context.Context, name string) (*models.Role, error) {
+	role, err := a.Repo.RoleGetByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRoleNotFound
+	}
+	return role, err
+}
+
+func (a *Auth) permissionGet(ctx context.Context, name string) (*models.Permission, error) {
+	permission, err := a.Repo.PermissionGetByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPermissionNotFound
+	}
+	return permission, err
+}
+
+func (a *Auth) rolePermissionGet(ctx context.Context, roleName, permissionName string) (*models.Role, *models.Permission, error) {
+	role, err := a.roleGet(ctx, roleName)
+	if err != nil {
+		return nil, nil, err
+	}
+	permission, err := a.permissionGet(ctx, permissionName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return role, permission, nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+func TestPermissions(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+
+	user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "permissions@example.com", Provider: "local"})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+
+	if _, err := auth.RoleCreate(ctx, "accountant", "Manages invoices"); err != nil {
+		t.Fatalf("RoleCreate() unexpected error: %v", err)
+	}
+	for _, name := range []string{"invoices:read", "invoices:write", "payroll:read"} {
+		if _, err := auth.PermissionCreate(ctx, name, ""); err != nil {
+			t.Fatalf("PermissionCreate() unexpected error: %v", err)
+		}
+	}
+	for _, name := range []string{"invoices:write", "invoices:read", "invoices:read"} {
+		if err := auth.RolePermissionGrant(ctx, "accountant", name); err != nil {
+			t.Fatalf("RolePermissionGrant() unexpected error: %v", err)
+		}
+	}
+
+	t.Run("InvalidNames", func(t *testing.T) {
+		if _, err := auth.RoleCreate(ctx, "a,b", ""); !errors.Is(err, ErrInvalidRoleName) {
+			t.Errorf("expected ErrInvalidRoleName, got %v", err)
+		}
+		if _, err := auth.PermissionCreate(ctx, " ", ""); !errors.Is(err, ErrInvalidPermissionName) {
+			t.Errorf("expected ErrInvalidPermissionName, got %v", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if _, err := auth.UserRoleAdd(ctx, user.ID, "unknown"); !errors.Is(err, ErrRoleNotFound) {
+			t.Errorf("expected ErrRoleNotFound, got %v", err)
+		}
+		if err := auth.RolePermissionGrant(ctx, "accountant", "unknown"); !errors.Is(err, ErrPermissionNotFound) {
+			t.Errorf("expected ErrPermissionNotFound, got %v", err)
+		}
+	})
+
+	t.Run("UserRoleAdd", func(t *testing.T) {
+		updated, err := auth.UserRoleAdd(ctx, user.ID, "accountant")
+		if err != nil {
+			t.Fatalf("UserRoleAdd() unexpected error: %v", err)
+		}
+		if !updated.Roles.Has("accountant") {
+			t.Fatalf("expected the user to have the accountant role, got %v", updated.Roles)
+		}
+
+		permissions, err := auth.UserPermissions(ctx, updated)
+		if err != nil {
+			t.Fatalf("UserPermissions() unexpected error: %v", err)
+		}
+		if want := []string{"invoices:read", "invoices:write"}; !reflect.DeepEqual(permissions, want) {
+			t.Errorf("expected permissions %v, got %v", want, permissions)
+		}
+
+		resp, err := auth.TokenCreate(ctx, updated)
+		if err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+		claims, err := auth.AccessTokenParse(resp.AccessToken)
+		if err != nil {
+			t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(claims["permissions"], []any{"invoices:read", "invoices:write"}) {
+			t.Errorf("expected permissions claim, got %v", claims["permissions"])
+		}
+	})
+
+	t.Run("RolePermissionRevoke", func(t *testing.T) {
+		if err := auth.RolePermissionRevoke(ctx, "accountant", "invoices:write"); err != nil {
+			t.Fatalf("RolePermissionRevoke() unexpected error: %v", err)
+		}
+		fetched, _ := auth.Repo.UserGetByID(ctx, user.ID)
+		permissions, err := auth.UserPermissions(ctx, fetched)
+		if err != nil {
+			t.Fatalf("UserPermissions() unexpected error: %v", err)
+		}
+		if want := []string{"invoices:read"}; !reflect.DeepEqual(permissions, want) {
+			t.Errorf("expected permissions %v, got %v", want, permissions)
+		}
+	})
+
+	t.Run("UserRoleRemove", func(t *testing.T) {
+		fetched, _ := auth.Repo.UserGetByID(ctx, user.ID)
+		old, _ := signTestAccessToken(t, auth, fetched, time.Now().Add(-time.Minute))
+
+		updated, err := auth.UserRoleRemove(ctx, user.ID, "accountant")
+		if err != nil {
+			t.Fatalf("UserRoleRemove() unexpected error: %v", err)
+		}
+		if len(updated.Roles) != 0 {
+			t.Errorf("expected no roles left, got %v", updated.Roles)
+		}
+		permissions, _ := auth.UserPermissions(ctx, updated)
+		if len(permissions) != 0 {
+			t.Errorf("expected no permissions left, got %v", permissions)
+		}
+		if _, err := auth.AccessTokenVerify(ctx, old); !errors.Is(err, ErrAccessTokenRevoked) {
+			t.Errorf("expected tokens issued with the role to be revoked, got %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		// grants counts the role_permissions rows left, SQLite does not cascade the deletes by itself
+		grants := func(t *testing.T) int {
+			t.Helper()
+			var count int
+			if err := auth.Repo.DB().QueryRowContext(ctx, "SELECT COUNT(*) FROM role_permissions").Scan(&count); err != nil {
+				t.Fatalf("failed to count grants: %v", err)
+			}
+			return count
+		}
+
+		if _, err := auth.RoleCreate(ctx, "auditor", ""); err != nil {
+			t.Fatalf("RoleCreate() unexpected error: %v", err)
+		}
+		for _, name := range []string{"invoices:read", "payroll:read"} {
+			if err := auth.RolePermissionGrant(ctx, "auditor", name); err != nil {
+				t.Fatalf("RolePermissionGrant() unexpected error: %v", err)
+			}
+		}
+		before := grants(t)
+
+		if err := auth.PermissionDelete(ctx, "payroll:read"); err != nil {
+			t.Fatalf("PermissionDelete() unexpected error: %v", err)
+		}
+		if got := grants(t); got != before-1 {
+			t.Errorf("expected the grant of the deleted permission to be removed, got %d grants instead of %d", got, before-1)
+		}
+
+		if err := auth.RoleDelete(ctx, "auditor"); err != nil {
+			t.Fatalf("RoleDelete() unexpected error: %v", err)
+		}
+		if got := grants(t); got != before-2 {
+			t.Errorf("expected the grants of the deleted role to be removed, got %d grants instead of %d", got, before-2)
+		}
+	})
+}
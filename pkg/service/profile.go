@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+	// Embedded so timezones validate on hosts without a zoneinfo database
+	_ "time/tzdata"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"golang.org/x/text/language"
+)
+
+var (
+	ErrInvalidLocale   = errors.New("invalid locale")
+	ErrInvalidTimezone = errors.New("invalid timezone")
+)
+
+// RequestUserUpdate defines the profile fields users may update themselves.
+// Empty fields are left unchanged, and a non nil UserMetadata replaces the current one.
+// Email, roles, provider and app metadata are not user-controlled and cannot be changed this way.
+type RequestUserUpdate struct {
+	FirstName    string         `json:"first_name"`
+	LastName     string         `json:"last_name"`
+	Locale       string         `json:"locale"`
+	Timezone     string         `json:"timezone"`
+	UserMetadata map[string]any `json:"user_metadata"`
+}
+
+// UserProfileUpdate updates the profile of a user and returns the updated user.
+// The locale must be a BCP 47 language tag, stored in its canonical form, and the timezone an IANA timezone name.
+func (a *Auth) UserProfileUpdate(ctx context.Context, userID string, req RequestUserUpdate) (*models.User, error) {
+	locale, err := localeCanonical(req.Locale)
+	if err != nil {
+		return nil, err
+	}
+	if err := timezoneValidate(req.Timezone); err != nil {
+		return nil, err
+	}
+
+	user, err := a.Repo.UserGetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.FirstName != "" {
+		user.FirstName = req.FirstName
+	}
+	if req.LastName != "" {
+		user.LastName = req.LastName
+	}
+	if locale != "" {
+		user.Locale = locale
+	}
+	if req.Timezone != "" {
+		user.Timezone = req.Timezone
+	}
+	if req.UserMetadata != nil {
+		user.UserMetadata = req.UserMetadata
+	}
+	return a.Repo.UserUpdate(ctx, user)
+}
+
+// localeCanonical returns the canonical form of a BCP 47 language tag, such as en-US for en_us.
+func localeCanonical(locale string) (string, error) {
+	if locale == "" {
+		return "", nil
+	}
+	tag, err := language.Parse(locale)
+	if err != nil || tag == language.Und {
+		return "", ErrInvalidLocale
+	}
+	return tag.String(), nil
+}
+
+// timezoneValidate checks that a timezone is an IANA timezone name such as Europe/Paris.
+func timezoneValidate(timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+	// Local depends on the host, it is not a timezone name
+	if timezone == "Local" {
+		return ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+func TestUserProfileUpdate(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+
+	user, err := auth.Repo.UserCreate(ctx, &models.User{
+		Email:        "profile@example.com",
+		Provider:     "local",
+		FirstName:    "John",
+		LastName:     "Doe",
+		Locale:       "en-US",
+		Timezone:     "UTC",
+		Roles:        models.Roles{"user"},
+		AppMetadata:  models.JSONMap{"plan": "free"},
+		UserMetadata: models.JSONMap{"theme": "light"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	t.Run("Update", func(t *testing.T) {
+		updated, err := auth.UserProfileUpdate(ctx, user.ID, RequestUserUpdate{
+			FirstName:    "Jane",
+			Locale:       "fr_ca",
+			Timezone:     "America/Montreal",
+			UserMetadata: map[string]any{"theme": "dark"},
+		})
+		if err != nil {
+			t.Fatalf("UserProfileUpdate() unexpected error: %v", err)
+		}
+		if updated.FirstName != "Jane" || updated.Locale != "fr-CA" || updated.Timezone != "America/Montreal" {
+			t.Errorf("unexpected profile: %+v", updated)
+		}
+		if updated.UserMetadata["theme"] != "dark" {
+			t.Errorf("expected user metadata to be replaced, got %v", updated.UserMetadata)
+		}
+
+		// Fields left out and fields users do not control are unchanged
+		if updated.LastName != "Doe" {
+			t.Errorf("expected last name to stay Doe, got %s", updated.LastName)
+		}
+		if updated.Email != user.Email || updated.Provider != user.Provider || updated.AppMetadata["plan"] != "free" || !updated.Roles.Has("user") {
+			t.Errorf("expected protected fields to be unchanged, got %+v", updated)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		tests := []struct {
+			name string
+			req  RequestUserUpdate
+			err  error
+		}{
+			{name: "Locale", req: RequestUserUpdate{Locale: "not a locale"}, err: ErrInvalidLocale},
+			{name: "UndeterminedLocale", req: RequestUserUpdate{Locale: "und"}, err: ErrInvalidLocale},
+			{name: "Timezone", req: RequestUserUpdate{Timezone: "Mars/Olympus_Mons"}, err: ErrInvalidTimezone},
+			{name: "LocalTimezone", req: RequestUserUpdate{Timezone: "Local"}, err: ErrInvalidTimezone},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := auth.UserProfileUpdate(ctx, user.ID, tt.req); !errors.Is(err, tt.err) {
+					t.Errorf("expected %v, got %v", tt.err, err)
+				}
+			})
+		}
+	})
+}
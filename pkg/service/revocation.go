@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/ezauth/pkg/db/repository"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+// DefaultRevocationSyncInterval is used when the configuration leaves Token.RevocationSyncInterval unset.
+const DefaultRevocationSyncInterval = 30 * time.Second
+
+var ErrAccessTokenRevoked = errors.New("access token revoked")
+
+// revocationList caches the revoked access tokens so verifying a token does not
+// cost a database round-trip. Revocations made through this instance apply
+// immediately; the cache is reloaded from the repository once per sync interval
+// to pick up the ones made by other instances.
+type revocationList struct {
+	mu       sync.RWMutex
+	syncMu   sync.Mutex
+	jtis     map[string]time.Time // jti digest -> token expiry
+	users    map[string]time.Time // user id -> tokens valid after
+	syncedAt time.Time
+}
+
+// AccessTokenVerify parses an access token like AccessTokenParse and also
+// rejects it with ErrAccessTokenRevoked if it has been revoked.
+func (a *Auth) AccessTokenVerify(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims, err := a.AccessTokenParse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.revocationsSync(ctx); err != nil {
+		return nil, err
+	}
+
+	jti, _ := claims["jti"].(string)
+	sub, _ := claims.GetSubject()
+	iat, _ := claims.GetIssuedAt()
+
+	r := a.revocations
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.jtis[repository.HashToken(jti)]; ok && jti != "" {
+		return nil, ErrAccessTokenRevoked
+	}
+	if validAfter, ok := r.users[sub]; ok && (iat == nil || iat.Before(validAfter)) {
+		return nil, ErrAccessTokenRevoked
+	}
+	return claims, nil
+}
+
+// AccessTokenRevoke revokes a single access token by its jti.
+// expiresAt is the expiry of the token, after which the revocation is no longer needed;
+// if zero, the configured access token lifetime is assumed.
+func (a *Auth) AccessTokenRevoke(ctx context.Context, userID, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return errors.New("jti is required")
+	}
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().UTC().Add(ttlOrDefault(a.Cfg.Token.AccessTTL, DefaultAccessTokenTTL))
+	}
+
+	digest := repository.HashToken(jti)
+	r := a.revocations
+	r.mu.RLock()
+	_, revoked := r.jtis[digest]
+	r.mu.RUnlock()
+	if revoked {
+		return nil
+	}
+
+	token := &models.Token{
+		UserID:    userID,
+		Token:     jti,
+		TokenType: models.TokenTypeAccess,
+		ExpiresAt: expiresAt.UTC(),
+		CreatedAt: time.Now().UTC(),
+		Revoked:   true,
+		Metadata:  models.JSONMap{},
+	}
+	if _, err := a.Repo.TokenCreate(ctx, token); err != nil {
+		// Already revoked by another instance
+		if _, getErr := a.Repo.TokenGetByToken(ctx, jti); getErr != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	if r.jtis == nil {
+		r.jtis = map[string]time.Time{}
+	}
+	r.jtis[digest] = expiresAt
+	r.mu.Unlock()
+
+	xlog.Info("access token revoked", "user_id", userID)
+	return nil
+}
+
+// AccessTokenRevokeClaims revokes the access token the given claims were parsed from.
+// Tokens without a jti cannot be revoked individually and are ignored.
+func (a *Auth) AccessTokenRevokeClaims(ctx context.Context, claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil
+	}
+	sub, _ := claims.GetSubject()
+	var expiresAt time.Time
+	if exp, _ := claims.GetExpirationTime(); exp != nil {
+		expiresAt = exp.Time
+	}
+	return a.AccessTokenRevoke(ctx, sub, jti, expiresAt)
+}
+
+// AccessTokenRevokeAll revokes every access token issued to a user so far.
+// Tokens issued in the same second as the revocation stay valid, since iat has
+// a one second precision.
+func (a *Auth) AccessTokenRevokeAll(ctx context.Context, userID string) error {
+	validAfter := time.Now().UTC().Truncate(time.Second)
+	if err := a.Repo.UserRevokeTokens(ctx, userID, validAfter); err != nil {
+		return err
+	}
+
+	r := a.revocations
+	r.mu.Lock()
+	if r.users == nil {
+		r.users = map[string]time.Time{}
+	}
+	r.users[userID] = validAfter
+	r.mu.Unlock()
+
+	xlog.Info("all access tokens revoked", "user_id", userID)
+	return nil
+}
+
+// revocationsSync reloads the revocation cache from the repository if it is older than the sync interval.
+// Once the cache has been loaded, a failed reload keeps the previous data until the next interval.
+func (a *Auth) revocationsSync(ctx context.Context) error {
+	r := a.revocations
+	interval := ttlOrDefault(a.Cfg.Token.RevocationSyncInterval, DefaultRevocationSyncInterval)
+
+	r.mu.RLock()
+	syncedAt := r.syncedAt
+	r.mu.RUnlock()
+	if time.Since(syncedAt) < interval {
+		return nil
+	}
+
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+
+	// Another request may have synced while we were waiting
+	r.mu.RLock()
+	syncedAt = r.syncedAt
+	r.mu.RUnlock()
+	if time.Since(syncedAt) < interval {
+		return nil
+	}
+
+	jtis, users, err := a.revocationsLoad(ctx)
+	if err != nil {
+		xlog.Error("failed to sync access token revocations", "error", err)
+		if syncedAt.IsZero() {
+			return err
+		}
+		r.mu.Lock()
+		r.syncedAt = time.Now()
+		r.mu.Unlock()
+		return nil
+	}
+
+	r.mu.Lock()
+	// Keep revocations made while loading; expired ones are dropped
+	now := time.Now()
+	for digest, exp := range r.jtis {
+		if _, ok := jtis[digest]; !ok && exp.After(now) {
+			jtis[digest] = exp
+		}
+	}
+	for userID, validAfter := range r.users {
+		if validAfter.After(users[userID]) {
+			users[userID] = validAfter
+		}
+	}
+	r.jtis = jtis
+	r.users = users
+	r.syncedAt = now
+	r.mu.Unlock()
+	return nil
+}
+
+func (a *Auth) revocationsLoad(ctx context.Context) (map[string]time.Time, map[string]time.Time, error) {
+	tokens, err := a.Repo.TokenListRevoked(ctx, models.TokenTypeAccess)
+	if err != nil {
+		return nil, nil, err
+	}
+	users, err := a.Repo.UserListTokensRevoked(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jtis := make(map[string]time.Time, len(tokens))
+	for _, token := range tokens {
+		jtis[token.Token] = token.ExpiresAt
+	}
+	validAfter := make(map[string]time.Time, len(users))
+	for _, user := range users {
+		if user.TokensValidAfter != nil {
+			validAfter[user.ID] = *user.TokensValidAfter
+		}
+	}
+	return jtis, validAfter, nil
+}
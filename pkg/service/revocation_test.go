@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+// signTestAccessToken issues an access token as if it had been issued at iat.
+func signTestAccessToken(t *testing.T, auth *Auth, user *models.User, iat time.Time) (string, jwt.MapClaims) {
+	t.Helper()
+	claims, err := auth.accessTokenClaims(context.Background(), user, time.Now().Add(time.Hour), "")
+	if err != nil {
+		t.Fatalf("accessTokenClaims() unexpected error: %v", err)
+	}
+	claims["iat"] = jwt.NewNumericDate(iat)
+
+	key := auth.Keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	if claims, err = auth.AccessTokenParse(tokenString); err != nil {
+		t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+	}
+	return tokenString, claims
+}
+
+func TestAccessTokenRevocation(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+
+	user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "revocation@example.com", Provider: "local"})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+
+	t.Run("RevokeByJTI", func(t *testing.T) {
+		revoked, claims := signTestAccessToken(t, auth, user, time.Now())
+		other, _ := signTestAccessToken(t, auth, user, time.Now())
+
+		if _, err := auth.AccessTokenVerify(ctx, revoked); err != nil {
+			t.Fatalf("AccessTokenVerify() unexpected error: %v", err)
+		}
+		exp, _ := claims.GetExpirationTime()
+		if err := auth.AccessTokenRevoke(ctx, user.ID, claims["jti"].(string), exp.Time); err != nil {
+			t.Fatalf("AccessTokenRevoke() unexpected error: %v", err)
+		}
+		// Revoking twice is a no-op
+		if err := auth.AccessTokenRevoke(ctx, user.ID, claims["jti"].(string), exp.Time); err != nil {
+			t.Fatalf("AccessTokenRevoke() twice unexpected error: %v", err)
+		}
+
+		if _, err := auth.AccessTokenVerify(ctx, revoked); !errors.Is(err, ErrAccessTokenRevoked) {
+			t.Errorf("expected ErrAccessTokenRevoked, got %v", err)
+		}
+		if _, err := auth.AccessTokenVerify(ctx, other); err != nil {
+			t.Errorf("expected other token to stay valid, got %v", err)
+		}
+	})
+
+	t.Run("RevokeAll", func(t *testing.T) {
+		old, _ := signTestAccessToken(t, auth, user, time.Now().Add(-time.Minute))
+		if err := auth.AccessTokenRevokeAll(ctx, user.ID); err != nil {
+			t.Fatalf("AccessTokenRevokeAll() unexpected error: %v", err)
+		}
+		if _, err := auth.AccessTokenVerify(ctx, old); !errors.Is(err, ErrAccessTokenRevoked) {
+			t.Errorf("expected ErrAccessTokenRevoked, got %v", err)
+		}
+
+		fresh, _, err := auth.generateAccessToken(ctx, user, time.Hour, "")
+		if err != nil {
+			t.Fatalf("generateAccessToken() unexpected error: %v", err)
+		}
+		if _, err := auth.AccessTokenVerify(ctx, fresh); err != nil {
+			t.Errorf("expected token issued after revocation to be valid, got %v", err)
+		}
+	})
+
+	t.Run("OtherInstance", func(t *testing.T) {
+		// A second service on the same database loads the revocations on first use
+		other, err := New(auth.Cfg, auth.Repo, auth.PathPrefix)
+		if err != nil {
+			t.Fatalf("New() unexpected error: %v", err)
+		}
+		old, _ := signTestAccessToken(t, auth, user, time.Now().Add(-time.Minute))
+		if _, err := other.AccessTokenVerify(ctx, old); !errors.Is(err, ErrAccessTokenRevoked) {
+			t.Errorf("expected ErrAccessTokenRevoked, got %v", err)
+		}
+
+		stored, err := auth.Repo.UserGetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if stored.TokensValidAfter == nil {
+			t.Error("expected tokens_valid_after to be persisted")
+		}
+	})
+
+	t.Run("OtherInstanceLocalTime", func(t *testing.T) {
+		// Expiry times are compared as text by SQLite, local times west of UTC would look expired
+		local := time.Local
+		time.Local = time.FixedZone("EST", -5*60*60)
+		defer func() { time.Local = local }()
+
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "revocation-local@example.com", Provider: "local"})
+		if err != nil {
+			t.Fatalf("failed to create test user: %v", err)
+		}
+		revoked, claims := signTestAccessToken(t, auth, user, time.Now())
+		exp, _ := claims.GetExpirationTime()
+		if err := auth.AccessTokenRevoke(ctx, user.ID, claims["jti"].(string), exp.Time); err != nil {
+			t.Fatalf("AccessTokenRevoke() unexpected error: %v", err)
+		}
+
+		other, err := New(auth.Cfg, auth.Repo, auth.PathPrefix)
+		if err != nil {
+			t.Fatalf("New() unexpected error: %v", err)
+		}
+		if _, err := other.AccessTokenVerify(ctx, revoked); !errors.Is(err, ErrAccessTokenRevoked) {
+			t.Errorf("expected ErrAccessTokenRevoked, got %v", err)
+		}
+	})
+}
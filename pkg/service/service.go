@@ -12,10 +12,17 @@ type Auth struct {
 	Repo       *repository.Repository
 	Mailer     Mailer
 	PathPrefix string
+	Keys       *Keyring
+
+	eventHandlers []EventHandler
+	claimsFuncs   []ClaimsFunc
+	revocations   *revocationList
+	activity      *activityTracker
 }
 
 // New creates a new Auth service with the given config and repository.
-func New(cfg *config.Config, repo *repository.Repository, pathPrefix string) *Auth {
+// It returns an error if the JWT signing key cannot be loaded.
+func New(cfg *config.Config, repo *repository.Repository, pathPrefix string) (*Auth, error) {
 	var mailer Mailer
 	if cfg.SMTP.Host != "" {
 		mailer = NewSMTPMailer(cfg.SMTP)
@@ -23,12 +30,20 @@ func New(cfg *config.Config, repo *repository.Repository, pathPrefix string) *Au
 		mailer = NewMockMailer()
 	}
 
-	return &Auth{
-		Cfg:        cfg,
-		Repo:       repo,
-		Mailer:     mailer,
-		PathPrefix: pathPrefix,
+	keys, err := NewKeyring(cfg)
+	if err != nil {
+		return nil, err
 	}
+
+	return &Auth{
+		Cfg:         cfg,
+		Repo:        repo,
+		Mailer:      mailer,
+		PathPrefix:  pathPrefix,
+		Keys:        keys,
+		revocations: &revocationList{},
+		activity:    &activityTracker{},
+	}, nil
 }
 
 // NewFromConfig creates a new Auth service from a config.
@@ -41,5 +56,14 @@ func NewFromConfig(cfg *config.Config, pathPrefix string) (*Auth, error) {
 	if err != nil {
 		return nil, err
 	}
-	return New(cfg, repo, pathPrefix), nil
+	return New(cfg, repo, pathPrefix)
+}
+
+// KeysReload reloads the JWT keyring from the configuration.
+// Call it after rotating key files to start signing with the new key
+// without invalidating tokens issued by the retired ones.
+// The key files are read again, but the configuration itself is not: to pick up a new
+// JWTSecret or JWT.PreviousSecrets, pass the updated configuration to Keys.Reload instead.
+func (a *Auth) KeysReload() error {
+	return a.Keys.Reload(a.Cfg)
 }
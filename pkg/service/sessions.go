@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/ezauth/pkg/util"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a login of a user on a device, backed by the active refresh token of a token family.
+// Its ID is the family ID, which access tokens carry in their sid claim.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// SessionList returns the active sessions of a user, most recently used first.
+// The session with ID currentID is flagged as the current one.
+func (a *Auth) SessionList(ctx context.Context, userID, currentID string) ([]Session, error) {
+	tokens, err := a.Repo.TokenListActive(ctx, userID, models.TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(tokens))
+	for _, token := range tokens {
+		session := Session{
+			ID:         sessionID(token),
+			CreatedAt:  sessionCreatedAt(token),
+			LastUsedAt: token.CreatedAt,
+			ExpiresAt:  token.ExpiresAt,
+		}
+		session.UserAgent, _ = token.Metadata[metadataUserAgent].(string)
+		session.IP, _ = token.Metadata[metadataIP].(string)
+		if lastUsed, ok := token.Metadata[metadataLastUsedAt].(string); ok {
+			if t, err := time.Parse(time.RFC3339, lastUsed); err == nil {
+				session.LastUsedAt = t
+			}
+		}
+		session.Current = currentID != "" && session.ID == currentID
+		sessions = append(sessions, session)
+	}
+
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// SessionRevoke revokes a session of a user by revoking its refresh tokens.
+// Access tokens already issued for the session stay valid until they expire.
+// It returns ErrSessionNotFound if the user has no active session with that ID.
+func (a *Auth) SessionRevoke(ctx context.Context, userID, id string) error {
+	tokens, err := a.Repo.TokenListActive(ctx, userID, models.TokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if sessionID(token) != id {
+			continue
+		}
+		if token.FamilyID != nil {
+			_, err = a.Repo.TokenRevokeFamily(ctx, *token.FamilyID)
+			return err
+		}
+		_, err = a.Repo.TokenRevoke(ctx, token.ID)
+		return err
+	}
+	return ErrSessionNotFound
+}
+
+// SessionRevokeAll logs a user out of every device: all refresh tokens are revoked
+// and every access token issued so far is rejected from now on.
+func (a *Auth) SessionRevokeAll(ctx context.Context, userID string) error {
+	revoked, err := a.Repo.TokenRevokeByUser(ctx, userID, models.TokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+	if err := a.AccessTokenRevokeAll(ctx, userID); err != nil {
+		return err
+	}
+	xlog.Info("user logged out of all sessions", "user_id", userID, "refresh_tokens", revoked)
+	return nil
+}
+
+// SessionRevokeOthers revokes the refresh tokens of every session of a user but the one with ID currentID.
+// Access tokens already issued for those sessions stay valid until they expire.
+func (a *Auth) SessionRevokeOthers(ctx context.Context, userID, currentID string) error {
+	tokens, err := a.Repo.TokenListActive(ctx, userID, models.TokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+
+	revoked := map[string]bool{currentID: true}
+	for _, token := range tokens {
+		id := sessionID(token)
+		if revoked[id] {
+			continue
+		}
+		revoked[id] = true
+		if err := a.SessionRevoke(ctx, userID, id); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionID identifies the session of a refresh token.
+// Tokens issued before token families were introduced are their own session.
+func sessionID(token *models.Token) string {
+	if id := util.Deref(token.FamilyID); id != "" {
+		return id
+	}
+	return token.ID
+}
+
+// sessionCreatedAt returns the time the session of a refresh token started.
+func sessionCreatedAt(token *models.Token) time.Time {
+	if createdAt, ok := token.Metadata[metadataCreatedAt].(string); ok {
+		if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
+			return t
+		}
+	}
+	return token.CreatedAt
+}
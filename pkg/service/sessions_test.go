@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+)
+
+func TestSessions(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+
+	user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "sessions@example.com", Provider: "local"})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+
+	phone, err := auth.TokenCreate(ctx, user, WithClientInfo("phone", "10.0.0.1"))
+	if err != nil {
+		t.Fatalf("TokenCreate() unexpected error: %v", err)
+	}
+	laptop, err := auth.TokenCreate(ctx, user, WithClientInfo("laptop", "10.0.0.2"))
+	if err != nil {
+		t.Fatalf("TokenCreate() unexpected error: %v", err)
+	}
+	// Rotating the phone's refresh token keeps the session and records the new IP
+	phone, err = auth.TokenRefresh(ctx, phone.RefreshToken, WithClientInfo("phone", "10.0.0.3"))
+	if err != nil {
+		t.Fatalf("TokenRefresh() unexpected error: %v", err)
+	}
+
+	claims, err := auth.AccessTokenParse(phone.AccessToken)
+	if err != nil {
+		t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+	}
+	phoneID, _ := claims["sid"].(string)
+	if phoneID == "" {
+		t.Fatal("expected a sid claim in the access token")
+	}
+
+	t.Run("List", func(t *testing.T) {
+		sessions, err := auth.SessionList(ctx, user.ID, phoneID)
+		if err != nil {
+			t.Fatalf("SessionList() unexpected error: %v", err)
+		}
+		if len(sessions) != 2 {
+			t.Fatalf("expected 2 sessions, got %d", len(sessions))
+		}
+
+		for _, s := range sessions {
+			switch s.UserAgent {
+			case "phone":
+				if !s.Current || s.ID != phoneID || s.IP != "10.0.0.3" {
+					t.Errorf("unexpected phone session: %+v", s)
+				}
+				if s.CreatedAt.After(s.LastUsedAt) {
+					t.Errorf("expected session creation before last use: %+v", s)
+				}
+			case "laptop":
+				if s.Current || s.IP != "10.0.0.2" {
+					t.Errorf("unexpected laptop session: %+v", s)
+				}
+			default:
+				t.Errorf("unexpected session: %+v", s)
+			}
+		}
+	})
+
+	t.Run("RevokeOtherUser", func(t *testing.T) {
+		if err := auth.SessionRevoke(ctx, "someone-else", phoneID); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected ErrSessionNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		if err := auth.SessionRevoke(ctx, user.ID, phoneID); err != nil {
+			t.Fatalf("SessionRevoke() unexpected error: %v", err)
+		}
+		if _, err := auth.TokenRefresh(ctx, phone.RefreshToken); err == nil {
+			t.Error("expected refresh of a revoked session to fail")
+		}
+		if _, err := auth.TokenRefresh(ctx, laptop.RefreshToken); err != nil {
+			t.Errorf("expected other session to stay active, got %v", err)
+		}
+
+		sessions, _ := auth.SessionList(ctx, user.ID, "")
+		if len(sessions) != 1 || sessions[0].UserAgent != "laptop" {
+			t.Errorf("expected only the laptop session, got %+v", sessions)
+		}
+	})
+
+	t.Run("ListLocalTime", func(t *testing.T) {
+		// Expiry times are compared as text by SQLite, local times west of UTC would look expired
+		local := time.Local
+		time.Local = time.FixedZone("EST", -5*60*60)
+		defer func() { time.Local = local }()
+
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "sessions-local@example.com", Provider: "local"})
+		if err != nil {
+			t.Fatalf("failed to create test user: %v", err)
+		}
+		if _, err := auth.TokenCreate(ctx, user, WithRefreshTokenTTL(2*time.Hour)); err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+
+		sessions, err := auth.SessionList(ctx, user.ID, "")
+		if err != nil {
+			t.Fatalf("SessionList() unexpected error: %v", err)
+		}
+		if len(sessions) != 1 {
+			t.Errorf("expected 1 session, got %d", len(sessions))
+		}
+	})
+}
+
+func TestSessionRevokeAll(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+
+	user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "sessions-all@example.com", Provider: "local"})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+	for range 3 {
+		if _, err := auth.TokenCreate(ctx, user); err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+	}
+	old, _ := signTestAccessToken(t, auth, user, time.Now().Add(-time.Minute))
+
+	if err := auth.SessionRevokeAll(ctx, user.ID); err != nil {
+		t.Fatalf("SessionRevokeAll() unexpected error: %v", err)
+	}
+
+	sessions, err := auth.SessionList(ctx, user.ID, "")
+	if err != nil {
+		t.Fatalf("SessionList() unexpected error: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("expected no sessions left, got %d", len(sessions))
+	}
+	if _, err := auth.AccessTokenVerify(ctx, old); !errors.Is(err, ErrAccessTokenRevoked) {
+		t.Errorf("expected ErrAccessTokenRevoked, got %v", err)
+	}
+}
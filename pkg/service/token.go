@@ -9,19 +9,135 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/ezauth/pkg/util"
+	"github.com/josuebrunel/gopkg/xlog"
 )
 
 // TokenResponse defines the structure of the token response.
+// The tokens are left out of responses in cookie mode, where they are set in cookies.
 type TokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 	ExpiresIn    int    `json:"expires_in"`
 	TokenType    string `json:"token_type"`
 }
 
+// Default token lifetimes, used when the configuration leaves them unset.
+const (
+	DefaultAccessTokenTTL   = time.Hour
+	DefaultRefreshTokenTTL  = 30 * 24 * time.Hour
+	DefaultPasswordResetTTL = time.Hour
+	DefaultMagicLinkTTL     = 15 * time.Minute
+)
+
+// Refresh token metadata keys. The lifetimes are the ones chosen when the token family was created,
+// the other keys describe the session the token family belongs to.
+const (
+	metadataAccessTTL  = "access_ttl"
+	metadataRefreshTTL = "refresh_ttl"
+	metadataUserAgent  = "user_agent"
+	metadataIP         = "ip"
+	metadataCreatedAt  = "created_at"
+	metadataLastUsedAt = "last_used_at"
+)
+
+// TokenOption overrides the configured token lifetimes for a single call
+// or records information about the session.
+type TokenOption func(*tokenOptions)
+
+type tokenOptions struct {
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	ttl        time.Duration
+	userAgent  string
+	ip         string
+	createdAt  time.Time
+}
+
+// WithAccessTokenTTL sets the lifetime of the access token.
+func WithAccessTokenTTL(d time.Duration) TokenOption {
+	return func(o *tokenOptions) {
+		o.accessTTL = d
+	}
+}
+
+// WithRefreshTokenTTL sets the lifetime of the refresh token.
+// Tokens rotated from it through TokenRefresh keep the same lifetime.
+func WithRefreshTokenTTL(d time.Duration) TokenOption {
+	return func(o *tokenOptions) {
+		o.refreshTTL = d
+	}
+}
+
+// WithClientInfo records the user agent and IP address of the client in the session.
+func WithClientInfo(userAgent, ip string) TokenOption {
+	return func(o *tokenOptions) {
+		o.userAgent = userAgent
+		o.ip = ip
+	}
+}
+
+// withSessionCreatedAt keeps the creation time of a session across refresh token rotations.
+func withSessionCreatedAt(t time.Time) TokenOption {
+	return func(o *tokenOptions) {
+		o.createdAt = t
+	}
+}
+
+// WithTokenTTL sets the lifetime of single-use tokens such as password reset tokens and magic links.
+func WithTokenTTL(d time.Duration) TokenOption {
+	return func(o *tokenOptions) {
+		o.ttl = d
+	}
+}
+
+func (a *Auth) tokenOptions(opts []TokenOption) tokenOptions {
+	o := tokenOptions{
+		accessTTL:  ttlOrDefault(a.Cfg.Token.AccessTTL, DefaultAccessTokenTTL),
+		refreshTTL: ttlOrDefault(a.Cfg.Token.RefreshTTL, DefaultRefreshTokenTTL),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func ttlOrDefault(ttl, def time.Duration) time.Duration {
+	if ttl <= 0 {
+		return def
+	}
+	return ttl
+}
+
+// ErrTokenUsed is returned when a single-use token, such as the one of an email link, has already been used.
+var ErrTokenUsed = errors.New("token already used")
+
+// ErrRefreshTokenReused is returned when a refresh token that has already been rotated is presented again.
+// The token family it belongs to is revoked, forcing every holder to log in again.
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
 // TokenCreate creates a new pair of access and refresh tokens for the given user.
-func (a *Auth) TokenCreate(ctx context.Context, user *models.User) (*TokenResponse, error) {
-	accessToken, exp, err := a.generateAccessToken(user)
+// The refresh token starts a new token family. Lifetimes default to the configuration
+// and can be overridden with WithAccessTokenTTL and WithRefreshTokenTTL.
+// It returns ErrUserDeleted if the account is pending deletion.
+//
+// The claims are built from the user as stored, so the roles signed into the token
+// are the ones given with UserRoleAdd, whatever the roles of the given user are.
+func (a *Auth) TokenCreate(ctx context.Context, user *models.User, opts ...TokenOption) (*TokenResponse, error) {
+	stored, err := a.Repo.UserGetByID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return a.tokenCreate(ctx, stored, util.RandomString(32), a.tokenOptions(opts))
+}
+
+func (a *Auth) tokenCreate(ctx context.Context, user *models.User, familyID string, o tokenOptions) (*TokenResponse, error) {
+	// Accounts pending deletion can neither log in nor refresh their tokens
+	if user.DeletedAt != nil {
+		return nil, ErrUserDeleted
+	}
+
+	accessToken, _, err := a.generateAccessToken(ctx, user, o.accessTTL, familyID)
 	if err != nil {
 		return nil, err
 	}
@@ -31,37 +147,73 @@ func (a *Auth) TokenCreate(ctx context.Context, user *models.User) (*TokenRespon
 		return nil, err
 	}
 
-	now := time.Now()
+	now := time.Now().UTC()
+	createdAt := o.createdAt
+	if createdAt.IsZero() {
+		createdAt = now
+	}
+	metadata := models.JSONMap{
+		metadataAccessTTL:  o.accessTTL.Seconds(),
+		metadataRefreshTTL: o.refreshTTL.Seconds(),
+		metadataCreatedAt:  createdAt.UTC().Format(time.RFC3339),
+		metadataLastUsedAt: now.UTC().Format(time.RFC3339),
+	}
+	if o.userAgent != "" {
+		metadata[metadataUserAgent] = o.userAgent
+	}
+	if o.ip != "" {
+		metadata[metadataIP] = o.ip
+	}
+
 	token := &models.Token{
 		UserID:    user.ID,
 		Token:     refreshToken,
 		TokenType: models.TokenTypeRefresh,
-		ExpiresAt: now.Add(30 * 24 * time.Hour),
+		ExpiresAt: now.Add(o.refreshTTL),
 		CreatedAt: now,
 		Revoked:   false,
-		Metadata:  models.JSONMap{},
+		Metadata:  metadata,
+		FamilyID:  &familyID,
 	}
 
 	if _, err := a.Repo.TokenCreate(ctx, token); err != nil {
 		return nil, err
 	}
+	a.ActivityTouch(ctx, user.ID)
 
 	return &TokenResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    int(time.Until(exp).Seconds()),
+		ExpiresIn:    int(o.accessTTL.Seconds()),
 		TokenType:    "Bearer",
 	}, nil
 }
 
 // TokenRefresh refreshes the access and refresh tokens using a valid refresh token.
-func (a *Auth) TokenRefresh(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+// The new tokens keep the lifetimes and client information of the token family unless overridden by opts.
+func (a *Auth) TokenRefresh(ctx context.Context, refreshToken string, opts ...TokenOption) (*TokenResponse, error) {
 	token, err := a.Repo.TokenGetByToken(ctx, refreshToken)
 	if err != nil {
 		return nil, errors.New("invalid refresh token")
 	}
 
+	// The tokens table also holds the tokens of emailed links, which are no session credentials
+	if token.TokenType != models.TokenTypeRefresh {
+		return nil, errors.New("invalid refresh token")
+	}
+
 	if token.Revoked {
+		// A revoked token whose family still has an active member has been rotated
+		// and replayed: either the legitimate client or an attacker holds a stolen copy.
+		if token.FamilyID != nil {
+			revoked, err := a.Repo.TokenRevokeFamily(ctx, *token.FamilyID)
+			if err != nil {
+				return nil, err
+			}
+			if revoked > 0 {
+				return nil, a.refreshTokenReused(ctx, token)
+			}
+		}
 		return nil, errors.New("token revoked")
 	}
 
@@ -74,13 +226,49 @@ func (a *Auth) TokenRefresh(ctx context.Context, refreshToken string) (*TokenRes
 		return nil, err
 	}
 
-	// Revoke old token
-	if err := a.Repo.TokenRevoke(ctx, token.ID); err != nil {
+	// Revoke old token; a concurrent refresh with the same token that revoked it first is a replay as well
+	revoked, err := a.Repo.TokenRevoke(ctx, token.ID)
+	if err != nil {
 		return nil, err
 	}
+	if revoked == 0 {
+		if token.FamilyID != nil {
+			if _, err := a.Repo.TokenRevokeFamily(ctx, *token.FamilyID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, a.refreshTokenReused(ctx, token)
+	}
+
+	// Create new tokens in the same family
+	familyID := util.Deref(token.FamilyID)
+	if familyID == "" {
+		familyID = util.RandomString(32)
+	}
+
+	var familyOpts []TokenOption
+	if ttl, ok := token.Metadata[metadataAccessTTL].(float64); ok {
+		familyOpts = append(familyOpts, WithAccessTokenTTL(time.Duration(ttl*float64(time.Second))))
+	}
+	if ttl, ok := token.Metadata[metadataRefreshTTL].(float64); ok {
+		familyOpts = append(familyOpts, WithRefreshTokenTTL(time.Duration(ttl*float64(time.Second))))
+	}
+	userAgent, _ := token.Metadata[metadataUserAgent].(string)
+	ip, _ := token.Metadata[metadataIP].(string)
+	familyOpts = append(familyOpts, WithClientInfo(userAgent, ip), withSessionCreatedAt(sessionCreatedAt(token)))
+	return a.tokenCreate(ctx, user, familyID, a.tokenOptions(append(familyOpts, opts...)))
+}
 
-	// Create new tokens
-	return a.TokenCreate(ctx, user)
+// refreshTokenReused reports the reuse of a refresh token whose family has been revoked and returns ErrRefreshTokenReused.
+func (a *Auth) refreshTokenReused(ctx context.Context, token *models.Token) error {
+	familyID := util.Deref(token.FamilyID)
+	xlog.Warn("refresh token reuse detected", "user_id", token.UserID, "family_id", familyID)
+	a.emit(ctx, Event{
+		Type:   EventRefreshTokenReused,
+		UserID: token.UserID,
+		Data:   map[string]any{"family_id": familyID, "token_id": token.ID},
+	})
+	return ErrRefreshTokenReused
 }
 
 // TokenRevoke revokes the given refresh token.
@@ -89,19 +277,61 @@ func (a *Auth) TokenRevoke(ctx context.Context, refreshToken string) error {
 	if err != nil {
 		return err
 	}
-	return a.Repo.TokenRevoke(ctx, token.ID)
+	_, err = a.Repo.TokenRevoke(ctx, token.ID)
+	return err
+}
+
+// AccessTokenParse verifies the signature and standard claims of an access token and returns its claims.
+func (a *Auth) AccessTokenParse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, a.accessTokenKeyFunc, a.accessTokenParserOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// JWKS returns the public keys that can be used to verify access tokens.
+// Shared secrets are never published.
+func (a *Auth) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range a.Keys.Keys() {
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
 }
 
-func (a *Auth) generateAccessToken(user *models.User) (string, time.Time, error) {
-	exp := time.Now().Add(1 * time.Hour)
-	claims := jwt.MapClaims{
-		"sub":   user.ID,
-		"email": user.Email,
-		"exp":   jwt.NewNumericDate(exp),
-		"iat":   jwt.NewNumericDate(time.Now()),
+func (a *Auth) accessTokenKeyFunc(token *jwt.Token) (any, error) {
+	// Tokens issued before kid headers were introduced are checked against the active key
+	key := a.Keys.Active()
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		if key, ok = a.Keys.Get(kid); !ok {
+			return nil, ErrUnknownKeyID
+		}
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(a.Cfg.JWTSecret))
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	return key.PublicKey, nil
+}
+
+func (a *Auth) generateAccessToken(ctx context.Context, user *models.User, ttl time.Duration, sessionID string) (string, time.Time, error) {
+	exp := time.Now().Add(ttl)
+	claims, err := a.accessTokenClaims(ctx, user, exp, sessionID)
+	if err != nil {
+		return "", exp, err
+	}
+	key := a.Keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	t, err := token.SignedString(key.PrivateKey)
 	return t, exp, err
 }
 
@@ -112,3 +342,60 @@ func (a *Auth) generateRefreshToken() (string, error) {
 	}
 	return hex.EncodeToString(b), nil
 }
+
+// actionTokenCreate stores a token of the given type, such as one sent in an email link, and returns its value.
+func (a *Auth) actionTokenCreate(ctx context.Context, userID, tokenType string, ttl time.Duration, metadata models.JSONMap) (string, error) {
+	tokenValue, err := a.generateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := &models.Token{
+		UserID:    userID,
+		Token:     tokenValue,
+		TokenType: tokenType,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+		CreatedAt: time.Now().UTC(),
+		Revoked:   false,
+		Metadata:  metadata,
+	}
+
+	if _, err := a.Repo.TokenCreate(ctx, token); err != nil {
+		return "", err
+	}
+	return tokenValue, nil
+}
+
+// actionTokenGet returns the token with the given value if it is of the given type and still usable.
+func (a *Auth) actionTokenGet(ctx context.Context, tokenValue, tokenType string) (*models.Token, error) {
+	token, err := a.Repo.TokenGetByToken(ctx, tokenValue)
+	if err != nil {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	if token.TokenType != tokenType {
+		return nil, errors.New("invalid token type")
+	}
+
+	if token.Revoked {
+		return nil, ErrTokenUsed
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return nil, errors.New("token expired")
+	}
+	return token, nil
+}
+
+// actionTokenUse marks a token returned by actionTokenGet as used, before acting on it.
+// Only one of concurrent uses of a token succeeds, the others get ErrTokenUsed.
+func (a *Auth) actionTokenUse(ctx context.Context, token *models.Token) error {
+	revoked, err := a.Repo.TokenRevoke(ctx, token.ID)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrTokenUsed
+	}
+	return nil
+}
@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/josuebrunel/ezauth/pkg/config"
 	"github.com/josuebrunel/ezauth/pkg/db/migrations"
 	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/ezauth/pkg/db/repository"
+	"github.com/josuebrunel/ezauth/pkg/util"
 	"github.com/josuebrunel/gopkg/xlog"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -86,6 +90,26 @@ func TestTokenOperations(t *testing.T) {
 		if storedToken.UserID != createdUser.ID {
 			t.Errorf("expected user id %s, got %s", createdUser.ID, storedToken.UserID)
 		}
+		if storedToken.Token != repository.HashToken(refreshToken) {
+			t.Error("expected only the token digest to be stored")
+		}
+	})
+
+	t.Run("TokenCreate_StoredRoles", func(t *testing.T) {
+		// Roles set on the given user but not stored are not signed into the token
+		spoofed := *createdUser
+		spoofed.Roles = models.Roles{"admin"}
+		resp, err := auth.TokenCreate(ctx, &spoofed)
+		if err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+		claims, err := auth.AccessTokenParse(resp.AccessToken)
+		if err != nil {
+			t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+		}
+		if roles := NewClaims(claims).Roles; len(roles) != 0 {
+			t.Errorf("expected no roles, got %v", roles)
+		}
 	})
 
 	t.Run("TokenRefresh", func(t *testing.T) {
@@ -141,3 +165,198 @@ func TestTokenOperations(t *testing.T) {
 		}
 	})
 }
+
+func TestTokenReuseDetection(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+
+	var events []Event
+	auth.OnEvent(func(ctx context.Context, e Event) {
+		events = append(events, e)
+	})
+
+	user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "reuse@example.com", Provider: "local"})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+
+	first, err := auth.TokenCreate(ctx, user)
+	if err != nil {
+		t.Fatalf("TokenCreate() unexpected error: %v", err)
+	}
+	second, err := auth.TokenRefresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("TokenRefresh() unexpected error: %v", err)
+	}
+
+	stored, err := auth.Repo.TokenGetByToken(ctx, second.RefreshToken)
+	if err != nil {
+		t.Fatalf("failed to get token from db: %v", err)
+	}
+	storedFirst, _ := auth.Repo.TokenGetByToken(ctx, first.RefreshToken)
+	if stored.FamilyID == nil || util.Deref(stored.FamilyID) != util.Deref(storedFirst.FamilyID) {
+		t.Fatalf("expected rotated token to stay in the same family, got %v and %v", stored.FamilyID, storedFirst.FamilyID)
+	}
+
+	// Replaying the rotated token revokes the whole family
+	if _, err := auth.TokenRefresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if len(events) != 1 || events[0].Type != EventRefreshTokenReused || events[0].UserID != user.ID {
+		t.Errorf("expected one reuse event, got %+v", events)
+	}
+
+	stored, _ = auth.Repo.TokenGetByToken(ctx, second.RefreshToken)
+	if !stored.Revoked {
+		t.Error("expected the active family member to be revoked")
+	}
+	if _, err := auth.TokenRefresh(ctx, second.RefreshToken); err == nil {
+		t.Error("expected refresh with a token of a compromised family to fail")
+	}
+}
+
+func TestTokenRefreshActionToken(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+
+	user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "refresh-action-token@example.com", Provider: "local"})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+
+	for _, tokenType := range []string{
+		models.TokenTypePasswordReset,
+		models.TokenTypeEmailVerification,
+		models.TokenTypeEmailChange,
+		models.TokenTypeEmailRevert,
+		models.TokenTypeAccountRestore,
+	} {
+		t.Run(tokenType, func(t *testing.T) {
+			value, err := auth.actionTokenCreate(ctx, user.ID, tokenType, time.Hour, models.JSONMap{})
+			if err != nil {
+				t.Fatalf("actionTokenCreate() unexpected error: %v", err)
+			}
+			if _, err := auth.TokenRefresh(ctx, value); err == nil {
+				t.Fatal("expected refresh with an action token to fail")
+			}
+
+			// The link still works
+			token, err := auth.actionTokenGet(ctx, value, tokenType)
+			if err != nil {
+				t.Fatalf("actionTokenGet() unexpected error: %v", err)
+			}
+			if token.Revoked {
+				t.Error("expected the action token to be left unused")
+			}
+		})
+	}
+}
+
+func TestActionTokenUse(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+
+	user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "action-token@example.com", Provider: "local"})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+	value, err := auth.actionTokenCreate(ctx, user.ID, models.TokenTypeEmailChange, time.Hour, models.JSONMap{})
+	if err != nil {
+		t.Fatalf("actionTokenCreate() unexpected error: %v", err)
+	}
+
+	// Two concurrent uses both get the token before either marks it as used
+	first, err := auth.actionTokenGet(ctx, value, models.TokenTypeEmailChange)
+	if err != nil {
+		t.Fatalf("actionTokenGet() unexpected error: %v", err)
+	}
+	second, err := auth.actionTokenGet(ctx, value, models.TokenTypeEmailChange)
+	if err != nil {
+		t.Fatalf("actionTokenGet() unexpected error: %v", err)
+	}
+
+	if err := auth.actionTokenUse(ctx, first); err != nil {
+		t.Fatalf("actionTokenUse() unexpected error: %v", err)
+	}
+	if err := auth.actionTokenUse(ctx, second); !errors.Is(err, ErrTokenUsed) {
+		t.Errorf("expected ErrTokenUsed, got %v", err)
+	}
+	if _, err := auth.actionTokenGet(ctx, value, models.TokenTypeEmailChange); !errors.Is(err, ErrTokenUsed) {
+		t.Errorf("expected ErrTokenUsed, got %v", err)
+	}
+}
+
+func TestTokenLifetimes(t *testing.T) {
+	auth := setupTestDB(t)
+	auth.Cfg.Token = config.Token{AccessTTL: 5 * time.Minute, RefreshTTL: 48 * time.Hour}
+	ctx := context.Background()
+
+	user, err := auth.Repo.UserCreate(ctx, &models.User{Email: "lifetimes@example.com", Provider: "local"})
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+
+	t.Run("Configured", func(t *testing.T) {
+		resp, err := auth.TokenCreate(ctx, user)
+		if err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+		if resp.ExpiresIn != 300 {
+			t.Errorf("expected expires_in 300, got %d", resp.ExpiresIn)
+		}
+
+		claims, err := auth.AccessTokenParse(resp.AccessToken)
+		if err != nil {
+			t.Fatalf("AccessTokenParse() unexpected error: %v", err)
+		}
+		exp, _ := claims.GetExpirationTime()
+		if d := time.Until(exp.Time); d > 5*time.Minute || d < 4*time.Minute {
+			t.Errorf("expected access token to expire in 5m, got %v", d)
+		}
+
+		stored, err := auth.Repo.TokenGetByToken(ctx, resp.RefreshToken)
+		if err != nil {
+			t.Fatalf("failed to get token from db: %v", err)
+		}
+		if d := time.Until(stored.ExpiresAt); d > 48*time.Hour || d < 47*time.Hour {
+			t.Errorf("expected refresh token to expire in 48h, got %v", d)
+		}
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		resp, err := auth.TokenCreate(ctx, user, WithAccessTokenTTL(time.Minute), WithRefreshTokenTTL(2*time.Hour))
+		if err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+		if resp.ExpiresIn != 60 {
+			t.Errorf("expected expires_in 60, got %d", resp.ExpiresIn)
+		}
+
+		// Refreshing keeps the lifetimes chosen for the session
+		refreshed, err := auth.TokenRefresh(ctx, resp.RefreshToken)
+		if err != nil {
+			t.Fatalf("TokenRefresh() unexpected error: %v", err)
+		}
+		if refreshed.ExpiresIn != 60 {
+			t.Errorf("expected refreshed expires_in 60, got %d", refreshed.ExpiresIn)
+		}
+		stored, err := auth.Repo.TokenGetByToken(ctx, refreshed.RefreshToken)
+		if err != nil {
+			t.Fatalf("failed to get token from db: %v", err)
+		}
+		if d := time.Until(stored.ExpiresAt); d > 2*time.Hour || d < time.Hour {
+			t.Errorf("expected refreshed token to expire in 2h, got %v", d)
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		auth.Cfg.Token = config.Token{}
+		resp, err := auth.TokenCreate(ctx, user)
+		if err != nil {
+			t.Fatalf("TokenCreate() unexpected error: %v", err)
+		}
+		if resp.ExpiresIn != int(DefaultAccessTokenTTL.Seconds()) {
+			t.Errorf("expected default expires_in, got %d", resp.ExpiresIn)
+		}
+	})
+}
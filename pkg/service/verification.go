@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"github.com/josuebrunel/gopkg/xlog"
+)
+
+// DefaultEmailVerificationTTL is used when the configuration leaves Token.EmailVerificationTTL unset.
+const DefaultEmailVerificationTTL = 24 * time.Hour
+
+var (
+	ErrEmailNotVerified     = errors.New("email not verified")
+	ErrEmailAlreadyVerified = errors.New("email already verified")
+)
+
+// RequestEmailVerification defines the parameters for requesting a new verification email.
+type RequestEmailVerification struct {
+	Email string `json:"email"`
+}
+
+// RequestEmailVerificationConfirm defines the parameters for confirming an email address.
+type RequestEmailVerificationConfirm struct {
+	Token string `json:"token"`
+}
+
+// EmailVerificationSend emails a verification token to the user, replacing the ones sent before.
+// The token lifetime defaults to the configuration and can be overridden with WithTokenTTL.
+func (a *Auth) EmailVerificationSend(ctx context.Context, user *models.User, opts ...TokenOption) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	tokenValue, err := a.generateRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	o := tokenOptions{ttl: ttlOrDefault(a.Cfg.Token.EmailVerificationTTL, DefaultEmailVerificationTTL)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Only the latest token is valid
+	if _, err := a.Repo.TokenRevokeByUser(ctx, user.ID, models.TokenTypeEmailVerification); err != nil {
+		return err
+	}
+
+	token := &models.Token{
+		UserID:    user.ID,
+		Token:     tokenValue,
+		TokenType: models.TokenTypeEmailVerification,
+		ExpiresAt: time.Now().UTC().Add(o.ttl),
+		CreatedAt: time.Now().UTC(),
+		Revoked:   false,
+		Metadata:  models.JSONMap{},
+	}
+
+	if _, err := a.Repo.TokenCreate(ctx, token); err != nil {
+		return err
+	}
+
+	// Send email
+	subject := "Verify your email address"
+	body := fmt.Sprintf("Welcome! Please use the following token to verify your email address: %s", tokenValue)
+	return a.Mailer.Send(user.Email, subject, body)
+}
+
+// EmailVerificationResend sends a new verification email to the user with the given email.
+// It does nothing if the user does not exist, is already verified or is pending deletion, to avoid leaking either.
+func (a *Auth) EmailVerificationResend(ctx context.Context, req RequestEmailVerification, opts ...TokenOption) error {
+	user, err := a.Repo.UserGetByEmail(ctx, req.Email)
+	if err != nil || user.EmailVerified || user.DeletedAt != nil {
+		return nil
+	}
+	return a.EmailVerificationSend(ctx, user, opts...)
+}
+
+// EmailVerificationConfirm marks the email of the user the token was sent to as verified.
+func (a *Auth) EmailVerificationConfirm(ctx context.Context, req RequestEmailVerificationConfirm) (*models.User, error) {
+	token, err := a.actionTokenGet(ctx, req.Token, models.TokenTypeEmailVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.Repo.UserGetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.actionTokenUse(ctx, token); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	user, err = a.Repo.UserUpdate(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	xlog.Info("email verified", "user_id", user.ID)
+	return user, nil
+}
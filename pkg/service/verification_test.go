@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/josuebrunel/ezauth/pkg/db/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestEmailVerification(t *testing.T) {
+	auth := setupTestDB(t)
+	ctx := context.Background()
+	mockMailer := auth.Mailer.(*MockMailer)
+
+	// lastToken extracts the token from the last email - "... verify your email address: <token>"
+	lastToken := func(t *testing.T, email string) string {
+		t.Helper()
+		if len(mockMailer.SentEmails) == 0 {
+			t.Fatal("expected an email to be sent")
+		}
+		sent := mockMailer.SentEmails[len(mockMailer.SentEmails)-1]
+		if sent["to"] != email {
+			t.Fatalf("expected email to %s, got %s", email, sent["to"])
+		}
+		body := sent["body"]
+		return body[len(body)-64:] // It's a 32-byte hex string = 64 chars
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		email := "verify-register@example.com"
+		user, err := auth.UserCreate(ctx, &RequestBasicAuth{Email: email, Password: "password123"})
+		if err != nil {
+			t.Fatalf("UserCreate() unexpected error: %v", err)
+		}
+		if user.EmailVerified {
+			t.Fatal("expected new user to be unverified")
+		}
+
+		verified, err := auth.EmailVerificationConfirm(ctx, RequestEmailVerificationConfirm{Token: lastToken(t, email)})
+		if err != nil {
+			t.Fatalf("EmailVerificationConfirm() unexpected error: %v", err)
+		}
+		if !verified.EmailVerified || verified.EmailVerifiedAt == nil {
+			t.Errorf("expected user to be verified, got %+v", verified)
+		}
+
+		user, err = auth.Repo.UserGetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if !user.EmailVerified || user.EmailVerifiedAt == nil {
+			t.Error("expected verification to be stored")
+		}
+	})
+
+	t.Run("Resend", func(t *testing.T) {
+		email := "verify-resend@example.com"
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: email, Provider: "local"})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		if err := auth.EmailVerificationSend(ctx, user); err != nil {
+			t.Fatalf("EmailVerificationSend() unexpected error: %v", err)
+		}
+		first := lastToken(t, email)
+
+		if err := auth.EmailVerificationResend(ctx, RequestEmailVerification{Email: email}); err != nil {
+			t.Fatalf("EmailVerificationResend() unexpected error: %v", err)
+		}
+		second := lastToken(t, email)
+
+		if _, err := auth.EmailVerificationConfirm(ctx, RequestEmailVerificationConfirm{Token: first}); err == nil {
+			t.Error("expected the replaced token to be rejected")
+		}
+		if _, err := auth.EmailVerificationConfirm(ctx, RequestEmailVerificationConfirm{Token: second}); err != nil {
+			t.Fatalf("EmailVerificationConfirm() unexpected error: %v", err)
+		}
+		if _, err := auth.EmailVerificationConfirm(ctx, RequestEmailVerificationConfirm{Token: second}); !errors.Is(err, ErrTokenUsed) {
+			t.Errorf("expected ErrTokenUsed for a used token, got %v", err)
+		}
+
+		// Nothing is sent to verified or unknown users
+		sent := len(mockMailer.SentEmails)
+		for _, email := range []string{email, "verify-unknown@example.com"} {
+			if err := auth.EmailVerificationResend(ctx, RequestEmailVerification{Email: email}); err != nil {
+				t.Errorf("EmailVerificationResend() unexpected error: %v", err)
+			}
+		}
+		if len(mockMailer.SentEmails) != sent {
+			t.Errorf("expected no email to be sent, got %d", len(mockMailer.SentEmails)-sent)
+		}
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		if _, err := auth.EmailVerificationConfirm(ctx, RequestEmailVerificationConfirm{Token: "invalid"}); err == nil {
+			t.Error("expected invalid token to be rejected")
+		}
+
+		// A password reset token cannot verify an email
+		email := "verify-type@example.com"
+		if _, err := auth.Repo.UserCreate(ctx, &models.User{Email: email, Provider: "local"}); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		if err := auth.PasswordResetRequest(ctx, RequestPasswordReset{Email: email}); err != nil {
+			t.Fatalf("PasswordResetRequest() unexpected error: %v", err)
+		}
+		if _, err := auth.EmailVerificationConfirm(ctx, RequestEmailVerificationConfirm{Token: lastToken(t, email)}); err == nil {
+			t.Error("expected password reset token to be rejected")
+		}
+	})
+
+	t.Run("RequireEmailVerification", func(t *testing.T) {
+		auth.Cfg.Features.RequireEmailVerification = true
+		defer func() { auth.Cfg.Features.RequireEmailVerification = false }()
+
+		email := "verify-required@example.com"
+		hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		user, err := auth.Repo.UserCreate(ctx, &models.User{Email: email, Provider: "local", PasswordHash: string(hash)})
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+
+		req := RequestBasicAuth{Email: email, Password: "password123"}
+		if _, err := auth.UserAuthenticate(ctx, req); !errors.Is(err, ErrEmailNotVerified) {
+			t.Fatalf("expected ErrEmailNotVerified, got %v", err)
+		}
+		if _, err := auth.UserAuthenticate(ctx, RequestBasicAuth{Email: email, Password: "wrong"}); errors.Is(err, ErrEmailNotVerified) {
+			t.Error("expected a wrong password not to reveal the verification status")
+		}
+
+		if err := auth.EmailVerificationSend(ctx, user); err != nil {
+			t.Fatalf("EmailVerificationSend() unexpected error: %v", err)
+		}
+		if _, err := auth.EmailVerificationConfirm(ctx, RequestEmailVerificationConfirm{Token: lastToken(t, email)}); err != nil {
+			t.Fatalf("EmailVerificationConfirm() unexpected error: %v", err)
+		}
+		if _, err := auth.UserAuthenticate(ctx, req); err != nil {
+			t.Errorf("expected verified user to log in, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default refresh settings of a RemoteKeySet.
+const (
+	DefaultRefreshInterval    = 15 * time.Minute
+	DefaultMinRefreshInterval = time.Minute
+)
+
+// RemoteKeySet is a KeySet fetching the keys from a JWKS URL, such as
+// https://auth.example.com/auth/.well-known/jwks.json. The keys are cached
+// and refreshed in the background, and early when a token carries an unknown
+// kid, so keys rotated in ezauth are picked up without a restart.
+type RemoteKeySet struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	mu        sync.RWMutex
+	keys      staticKeySet
+	fetchedAt time.Time
+
+	// refreshMu serializes fetches so concurrent misses trigger a single request
+	refreshMu sync.Mutex
+}
+
+// RemoteOption configures a RemoteKeySet.
+type RemoteOption func(*RemoteKeySet)
+
+// WithHTTPClient sets the client used to fetch the keys. It defaults to a client with a 10 seconds timeout.
+func WithHTTPClient(client *http.Client) RemoteOption {
+	return func(s *RemoteKeySet) {
+		s.client = client
+	}
+}
+
+// WithRefreshInterval sets how often the keys are refreshed in the background.
+func WithRefreshInterval(d time.Duration) RemoteOption {
+	return func(s *RemoteKeySet) {
+		s.refreshInterval = d
+	}
+}
+
+// WithMinRefreshInterval sets the minimum time between two fetches triggered by unknown kids,
+// which keeps tokens with made-up kids from hammering the JWKS endpoint.
+func WithMinRefreshInterval(d time.Duration) RemoteOption {
+	return func(s *RemoteKeySet) {
+		s.minRefreshInterval = d
+	}
+}
+
+// NewRemoteKeySet returns a KeySet for the keys published at url.
+// The keys are refreshed in the background until ctx is done.
+func NewRemoteKeySet(ctx context.Context, url string, opts ...RemoteOption) *RemoteKeySet {
+	s := &RemoteKeySet{
+		url:                url,
+		client:             &http.Client{Timeout: 10 * time.Second},
+		refreshInterval:    DefaultRefreshInterval,
+		minRefreshInterval: DefaultMinRefreshInterval,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	go s.run(ctx)
+	return s
+}
+
+func (s *RemoteKeySet) run(ctx context.Context) {
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Errors keep the cached keys; the next tick or an unknown kid retries
+			s.Refresh(ctx)
+		}
+	}
+}
+
+// VerificationKeys returns the cached keys, fetching them first if they were never
+// fetched or if kid is unknown and the keys were not fetched in the last minimum refresh interval.
+func (s *RemoteKeySet) VerificationKeys(ctx context.Context, kid, alg string) ([]any, error) {
+	keys, fetchedAt, known := s.lookup(kid, alg)
+	if known {
+		return keys, nil
+	}
+	if !fetchedAt.IsZero() && time.Since(fetchedAt) < s.minRefreshInterval {
+		return keys, nil
+	}
+
+	if err := s.refresh(ctx, fetchedAt); err != nil {
+		if fetchedAt.IsZero() {
+			return nil, err
+		}
+		// Fall back to the cached keys if the endpoint is unavailable
+		return keys, nil
+	}
+	keys, _, _ = s.lookup(kid, alg)
+	return keys, nil
+}
+
+// lookup returns the cached keys for kid and alg, when they were fetched,
+// and whether kid was found, or the token has no kid and keys were fetched.
+func (s *RemoteKeySet) lookup(kid, alg string) ([]any, time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.fetchedAt.IsZero() {
+		return nil, s.fetchedAt, false
+	}
+	if kid == "" {
+		return s.keys.lookup(kid, alg), s.fetchedAt, true
+	}
+	for _, key := range s.keys {
+		if key.id == kid && key.alg == alg {
+			return []any{key.key}, s.fetchedAt, true
+		}
+	}
+	return nil, s.fetchedAt, false
+}
+
+// Refresh fetches the keys now.
+func (s *RemoteKeySet) Refresh(ctx context.Context) error {
+	return s.refresh(ctx, time.Time{})
+}
+
+// refresh fetches the keys unless another call did since seen.
+func (s *RemoteKeySet) refresh(ctx context.Context, seen time.Time) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.RLock()
+	fetchedAt := s.fetchedAt
+	s.mu.RUnlock()
+	if !seen.IsZero() && fetchedAt.After(seen) {
+		return nil
+	}
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) (staticKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode jwks: %w", err)
+	}
+
+	keys := staticKeySet{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys that cannot be decoded are skipped rather than failing the whole set
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		alg := keyAlgorithm(pub)
+		if k.Alg != "" && k.Alg != alg {
+			continue
+		}
+		keys = append(keys, verificationKey{id: k.Kid, alg: alg, key: pub})
+	}
+	return keys, nil
+}
@@ -0,0 +1,176 @@
+package verifier
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// KeySet provides the keys that may have signed a token.
+type KeySet interface {
+	// VerificationKeys returns the keys to check a token signed with alg against.
+	// kid is the kid header of the token, empty for tokens without one.
+	VerificationKeys(ctx context.Context, kid, alg string) ([]any, error)
+}
+
+// verificationKey is a key of a KeySet.
+type verificationKey struct {
+	id  string
+	alg string
+	key any
+}
+
+// staticKeySet is a fixed set of keys. Tokens with an unknown or no kid are
+// checked against every key of their algorithm.
+type staticKeySet []verificationKey
+
+// Secrets returns a KeySet for tokens signed with HS256, the default algorithm of ezauth.
+// Pass JWT_SECRET first, then JWT_PREVIOUS_SECRETS while they are still accepted.
+func Secrets(secrets ...string) KeySet {
+	set := staticKeySet{}
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(secret))
+		set = append(set, verificationKey{id: "hs-" + b64(sum[:8]), alg: AlgHS256, key: []byte(secret)})
+	}
+	return set
+}
+
+// PublicKeys returns a KeySet for tokens signed with RS256, ES256 or EdDSA.
+// Keys of other types are ignored.
+func PublicKeys(keys ...crypto.PublicKey) KeySet {
+	set := staticKeySet{}
+	for _, key := range keys {
+		if alg := keyAlgorithm(key); alg != "" {
+			set = append(set, verificationKey{alg: alg, key: key})
+		}
+	}
+	return set
+}
+
+func (s staticKeySet) VerificationKeys(_ context.Context, kid, alg string) ([]any, error) {
+	return s.lookup(kid, alg), nil
+}
+
+func (s staticKeySet) lookup(kid, alg string) []any {
+	var keys []any
+	for _, key := range s {
+		if key.alg != alg {
+			continue
+		}
+		if kid != "" && key.id == kid {
+			return []any{key.key}
+		}
+		keys = append(keys, key.key)
+	}
+	return keys
+}
+
+// ParsePublicKeyPEM parses a PEM encoded public key, such as the retired keys written by ezauth's key rotation.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+// keyAlgorithm returns the signing algorithm ezauth uses with key.
+func keyAlgorithm(key any) string {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return AlgRS256
+	case *ecdsa.PublicKey:
+		return AlgES256
+	case ed25519.PublicKey:
+		return AlgEdDSA
+	default:
+		return ""
+	}
+}
+
+// jwk is a JSON Web Key as defined in RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// publicKey decodes the public key held by the JWK.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := unb64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := unb64(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := unb64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := unb64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// Uncompressed point: 0x04 || X || Y
+		point := append([]byte{4}, append(x, y...)...)
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := unb64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func unb64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
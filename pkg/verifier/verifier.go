@@ -0,0 +1,202 @@
+// Package verifier validates ezauth access tokens in downstream services.
+//
+// Unlike the handler package it has no database, router or service dependency:
+// tokens are checked against a shared secret, static public keys or the JWKS
+// published by ezauth on /.well-known/jwks.json. Verification is stateless,
+// so revoked access tokens stay valid until they expire.
+package verifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported JWT signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrTokenRequired = errors.New("token is required")
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrUnknownKeyID  = errors.New("unknown signing key id")
+)
+
+// Verifier validates access tokens against a KeySet.
+type Verifier struct {
+	keys          KeySet
+	parserOptions []jwt.ParserOption
+}
+
+// Option configures a Verifier.
+type Option func(*options)
+
+type options struct {
+	issuer   string
+	audience []string
+	leeway   time.Duration
+}
+
+// WithIssuer requires the iss claim to be issuer, the JWT_ISSUER of the ezauth instance.
+func WithIssuer(issuer string) Option {
+	return func(o *options) {
+		o.issuer = issuer
+	}
+}
+
+// WithAudience requires the aud claim to contain at least one of audience.
+func WithAudience(audience ...string) Option {
+	return func(o *options) {
+		o.audience = append(o.audience, audience...)
+	}
+}
+
+// WithLeeway allows for a clock skew of d between ezauth and the service when checking exp, nbf and iat.
+func WithLeeway(d time.Duration) Option {
+	return func(o *options) {
+		o.leeway = d
+	}
+}
+
+// New returns a Verifier checking tokens against keys.
+// Tokens must carry an exp claim; nbf and iat are checked when present.
+func New(keys KeySet, opts ...Option) *Verifier {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(o.leeway),
+	}
+	if o.issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(o.issuer))
+	}
+	if len(o.audience) > 0 {
+		parserOptions = append(parserOptions, jwt.WithAudience(o.audience...))
+	}
+	return &Verifier{keys: keys, parserOptions: parserOptions}
+}
+
+// Verify checks the signature and claims of an access token and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*Claims, error) {
+	if tokenString == "" {
+		return nil, ErrTokenRequired
+	}
+
+	token, err := jwt.Parse(tokenString, v.keyFunc(ctx), v.parserOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return newClaims(claims), nil
+}
+
+func (v *Verifier) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		keys, err := v.keys.VerificationKeys(ctx, kid, token.Method.Alg())
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, ErrUnknownKeyID
+		}
+
+		set := jwt.VerificationKeySet{}
+		for _, key := range keys {
+			set.Keys = append(set.Keys, key)
+		}
+		return set, nil
+	}
+}
+
+// Claims are the verified claims of an ezauth access token.
+type Claims struct {
+	Subject     string         `json:"sub"`
+	Email       string         `json:"email"`
+	Roles       []string       `json:"roles"`
+	Permissions []string       `json:"permissions"`
+	SessionID   string         `json:"sid,omitempty"`
+	ID          string         `json:"jti"`
+	Issuer      string         `json:"iss,omitempty"`
+	Audience    []string       `json:"aud,omitempty"`
+	IssuedAt    time.Time      `json:"iat"`
+	ExpiresAt   time.Time      `json:"exp"`
+	Custom      map[string]any `json:"custom,omitempty"` // other claims, such as app_metadata and the ones added with OnClaims
+}
+
+// HasRole reports whether the claims hold role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// HasAnyRole reports whether the claims hold at least one of roles.
+func (c *Claims) HasAnyRole(roles ...string) bool {
+	return slices.ContainsFunc(roles, c.HasRole)
+}
+
+// HasPermission reports whether the claims grant permission.
+func (c *Claims) HasPermission(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
+
+// knownClaims are mapped to fields of Claims rather than Custom.
+var knownClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true, "sid": true,
+	"email": true, "roles": true, "permissions": true,
+}
+
+func newClaims(claims jwt.MapClaims) *Claims {
+	c := &Claims{Custom: map[string]any{}}
+	c.Subject, _ = claims.GetSubject()
+	c.Issuer, _ = claims.GetIssuer()
+	c.Audience, _ = claims.GetAudience()
+	c.Email, _ = claims["email"].(string)
+	c.SessionID, _ = claims["sid"].(string)
+	c.ID, _ = claims["jti"].(string)
+	if iat, _ := claims.GetIssuedAt(); iat != nil {
+		c.IssuedAt = iat.Time
+	}
+	if exp, _ := claims.GetExpirationTime(); exp != nil {
+		c.ExpiresAt = exp.Time
+	}
+	c.Roles = claimStrings(claims["roles"])
+	c.Permissions = claimStrings(claims["permissions"])
+
+	for k, v := range claims {
+		if !knownClaims[k] {
+			c.Custom[k] = v
+		}
+	}
+	return c
+}
+
+// claimStrings returns the value of a claim holding a list of strings.
+func claimStrings(v any) []string {
+	list, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
@@ -0,0 +1,358 @@
+package verifier
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/josuebrunel/ezauth/pkg/config"
+	"github.com/josuebrunel/ezauth/pkg/service"
+)
+
+func signToken(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return s
+}
+
+func testClaims(ttl time.Duration) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":         "user-123",
+		"email":       "verifier@example.com",
+		"roles":       []string{"admin"},
+		"permissions": []string{"posts:write"},
+		"jti":         "jti-123",
+		"iat":         now.Unix(),
+		"exp":         now.Add(ttl).Unix(),
+		"tenant":      "acme",
+	}
+}
+
+func hmacKeyID(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "hs-" + b64(sum[:8])
+}
+
+func TestVerifier_Secrets(t *testing.T) {
+	ctx := context.Background()
+	v := New(Secrets("current-secret", "previous-secret"))
+
+	t.Run("ValidToken", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodHS256, []byte("current-secret"), hmacKeyID("current-secret"), testClaims(time.Hour))
+		claims, err := v.Verify(ctx, token)
+		if err != nil {
+			t.Fatalf("Verify() unexpected error: %v", err)
+		}
+		if claims.Subject != "user-123" || claims.Email != "verifier@example.com" || claims.ID != "jti-123" {
+			t.Errorf("unexpected claims: %+v", claims)
+		}
+		if !claims.HasRole("admin") || !claims.HasAnyRole("user", "admin") || !claims.HasPermission("posts:write") {
+			t.Errorf("expected roles and permissions to be mapped, got %v and %v", claims.Roles, claims.Permissions)
+		}
+		if claims.Custom["tenant"] != "acme" {
+			t.Errorf("expected custom claim tenant, got %v", claims.Custom)
+		}
+	})
+
+	t.Run("PreviousSecret", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodHS256, []byte("previous-secret"), hmacKeyID("previous-secret"), testClaims(time.Hour))
+		if _, err := v.Verify(ctx, token); err != nil {
+			t.Errorf("Verify() unexpected error: %v", err)
+		}
+	})
+
+	t.Run("NoKeyID", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodHS256, []byte("current-secret"), "", testClaims(time.Hour))
+		if _, err := v.Verify(ctx, token); err != nil {
+			t.Errorf("Verify() unexpected error: %v", err)
+		}
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodHS256, []byte("other-secret"), "", testClaims(time.Hour))
+		if _, err := v.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("MissingToken", func(t *testing.T) {
+		if _, err := v.Verify(ctx, ""); !errors.Is(err, ErrTokenRequired) {
+			t.Errorf("expected ErrTokenRequired, got %v", err)
+		}
+	})
+
+	t.Run("MissingExpiration", func(t *testing.T) {
+		claims := testClaims(time.Hour)
+		delete(claims, "exp")
+		token := signToken(t, jwt.SigningMethodHS256, []byte("current-secret"), "", claims)
+		if _, err := v.Verify(ctx, token); !errors.Is(err, jwt.ErrTokenRequiredClaimMissing) {
+			t.Errorf("expected ErrTokenRequiredClaimMissing, got %v", err)
+		}
+	})
+}
+
+func TestVerifier_TimeClaims(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("time-secret")
+
+	expired := signToken(t, jwt.SigningMethodHS256, secret, "", testClaims(-10*time.Second))
+	notYetValid := testClaims(time.Hour)
+	notYetValid["nbf"] = time.Now().Add(10 * time.Second).Unix()
+	early := signToken(t, jwt.SigningMethodHS256, secret, "", notYetValid)
+
+	strict := New(Secrets(string(secret)))
+	if _, err := strict.Verify(ctx, expired); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired, got %v", err)
+	}
+	if _, err := strict.Verify(ctx, early); !errors.Is(err, jwt.ErrTokenNotValidYet) {
+		t.Errorf("expected ErrTokenNotValidYet, got %v", err)
+	}
+
+	lenient := New(Secrets(string(secret)), WithLeeway(time.Minute))
+	if _, err := lenient.Verify(ctx, expired); err != nil {
+		t.Errorf("expected expired token within leeway to be valid, got %v", err)
+	}
+	if _, err := lenient.Verify(ctx, early); err != nil {
+		t.Errorf("expected early token within leeway to be valid, got %v", err)
+	}
+}
+
+func TestVerifier_IssuerAndAudience(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("iss-secret")
+	claims := testClaims(time.Hour)
+	claims["iss"] = "https://auth.example.com"
+	claims["aud"] = []string{"api"}
+	token := signToken(t, jwt.SigningMethodHS256, secret, "", claims)
+
+	tests := []struct {
+		name string
+		opts []Option
+		err  error
+	}{
+		{name: "Match", opts: []Option{WithIssuer("https://auth.example.com"), WithAudience("other", "api")}},
+		{name: "WrongIssuer", opts: []Option{WithIssuer("https://evil.example.com")}, err: jwt.ErrTokenInvalidIssuer},
+		{name: "WrongAudience", opts: []Option{WithAudience("other")}, err: jwt.ErrTokenInvalidAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(Secrets(string(secret)), tt.opts...).Verify(ctx, token)
+			if tt.err == nil && err != nil {
+				t.Errorf("Verify() unexpected error: %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestVerifier_PublicKeys(t *testing.T) {
+	ctx := context.Background()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := New(PublicKeys(&ecKey.PublicKey, edPub))
+
+	for _, tt := range []struct {
+		method jwt.SigningMethod
+		key    any
+	}{
+		{method: jwt.SigningMethodES256, key: ecKey},
+		{method: jwt.SigningMethodEdDSA, key: edKey},
+	} {
+		t.Run(tt.method.Alg(), func(t *testing.T) {
+			token := signToken(t, tt.method, tt.key, "some-kid", testClaims(time.Hour))
+			if _, err := v.Verify(ctx, token); err != nil {
+				t.Errorf("Verify() unexpected error: %v", err)
+			}
+		})
+	}
+
+	t.Run("UnknownAlgorithm", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodHS256, []byte("secret"), "", testClaims(time.Hour))
+		if _, err := v.Verify(ctx, token); !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("expected ErrUnknownKeyID, got %v", err)
+		}
+	})
+}
+
+// jwksServer serves the JWKS of an ezauth keyring and counts the requests it receives.
+func jwksServer(t *testing.T, auth *service.Auth) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(auth.JWKS())
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func newTestKeyring(t *testing.T, alg string) *service.Keyring {
+	t.Helper()
+	keys, err := service.NewKeyring(&config.Config{JWT: config.JWT{Algorithm: alg}})
+	if err != nil {
+		t.Fatalf("NewKeyring() unexpected error: %v", err)
+	}
+	return keys
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, alg := range []string{service.AlgRS256, service.AlgES256, service.AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			auth := &service.Auth{Keys: newTestKeyring(t, alg)}
+			srv, hits := jwksServer(t, auth)
+			v := New(NewRemoteKeySet(ctx, srv.URL, WithMinRefreshInterval(0)))
+
+			key := auth.Keys.Active()
+			for range 3 {
+				token := signToken(t, key.Method, key.PrivateKey, key.ID, testClaims(time.Hour))
+				if _, err := v.Verify(ctx, token); err != nil {
+					t.Fatalf("Verify() unexpected error: %v", err)
+				}
+			}
+			if n := hits.Load(); n != 1 {
+				t.Errorf("expected keys to be fetched once, got %d fetches", n)
+			}
+		})
+	}
+}
+
+func TestRemoteKeySet_Rotation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	auth := &service.Auth{Keys: newTestKeyring(t, service.AlgES256)}
+	srv, hits := jwksServer(t, auth)
+	keys := NewRemoteKeySet(ctx, srv.URL, WithMinRefreshInterval(time.Hour))
+	v := New(keys)
+
+	old := auth.Keys.Active()
+	if _, err := v.Verify(ctx, signToken(t, old.Method, old.PrivateKey, old.ID, testClaims(time.Hour))); err != nil {
+		t.Fatalf("Verify() unexpected error: %v", err)
+	}
+
+	// Unknown kids do not trigger a fetch within the minimum refresh interval
+	auth.Keys = newTestKeyring(t, service.AlgES256)
+	rotated := auth.Keys.Active()
+	token := signToken(t, rotated.Method, rotated.PrivateKey, rotated.ID, testClaims(time.Hour))
+	if _, err := v.Verify(ctx, token); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("expected ErrUnknownKeyID, got %v", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected 1 fetch, got %d", n)
+	}
+
+	if err := keys.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Errorf("expected rotated key to be accepted after refresh, got %v", err)
+	}
+}
+
+func TestRemoteKeySet_BackgroundRefresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	auth := &service.Auth{Keys: newTestKeyring(t, service.AlgEdDSA)}
+	srv, hits := jwksServer(t, auth)
+	NewRemoteKeySet(ctx, srv.URL, WithRefreshInterval(10*time.Millisecond))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hits.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := hits.Load(); n < 2 {
+		t.Errorf("expected keys to be refreshed in the background, got %d fetches", n)
+	}
+}
+
+func TestRemoteKeySet_Unavailable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	v := New(NewRemoteKeySet(ctx, srv.URL))
+	token := signToken(t, jwt.SigningMethodHS256, []byte("secret"), "", testClaims(time.Hour))
+	if _, err := v.Verify(ctx, token); err == nil {
+		t.Error("expected error when the keys cannot be fetched")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	secret := []byte("middleware-secret")
+	v := New(Secrets(string(secret)))
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := FromContext(r.Context())
+		if !ok {
+			t.Error("expected claims in context")
+			return
+		}
+		w.Write([]byte(claims.Subject))
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{name: "ValidToken", header: "Bearer " + signToken(t, jwt.SigningMethodHS256, secret, "", testClaims(time.Hour)), status: http.StatusOK},
+		{name: "MissingToken", status: http.StatusUnauthorized},
+		{name: "WrongScheme", header: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized},
+		{name: "InvalidToken", header: "Bearer invalid-token", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+			if tt.status == http.StatusOK && rr.Body.String() != "user-123" {
+				t.Errorf("expected subject in body, got %q", rr.Body.String())
+			}
+			if tt.status == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header")
+			}
+		})
+	}
+}